@@ -11,23 +11,31 @@ package ast
 import (
 	"bytes"
 	"monkey/token"
+	"strings"
 )
 
 // A let statement binds an identifier to some value produced by an expression
-// Example: `let myIdentifier = 5;`
+// Example: `let myIdentifier = 5;` or annotated `let myIdentifier: int = 5;`
 type LetStatement struct {
 	Token token.Token // token.LET
 	Name  *Identifier
+	Type  *TypeName // optional type annotation, nil when absent
 	Value Expression
 }
 
 func (n *LetStatement) statementNode()       {}
 func (n *LetStatement) TokenLiteral() string { return n.Token.Literal }
+func (n *LetStatement) Pos() token.Position  { return n.Token.Pos }
 func (n *LetStatement) String() string {
 	var out bytes.Buffer
 
 	out.WriteString(n.TokenLiteral() + " ")
 	out.WriteString(n.Name.String())
+
+	if n.Type != nil {
+		out.WriteString(": " + n.Type.String())
+	}
+
 	out.WriteString(" = ")
 
 	if n.Value != nil {
@@ -39,6 +47,29 @@ func (n *LetStatement) String() string {
 	return out.String()
 }
 
+// A line comment, e.g. `// explains the next line`
+// Comments are not part of the statements of a program, the parser collects
+// them in Program.Comments
+type Comment struct {
+	Token token.Token // token.COMMENT
+	Text  string      // the comment text, including the leading `//`
+}
+
+func (n *Comment) TokenLiteral() string { return n.Token.Literal }
+func (n *Comment) Pos() token.Position  { return n.Token.Pos }
+func (n *Comment) String() string       { return n.Text }
+
+// The name of a type in a type annotation
+// Example: the `int` in `let x: int = 5;`
+type TypeName struct {
+	Token token.Token // token.IDENT
+	Name  string
+}
+
+func (n *TypeName) TokenLiteral() string { return n.Token.Literal }
+func (n *TypeName) Pos() token.Position  { return n.Token.Pos }
+func (n *TypeName) String() string       { return n.Name }
+
 type Identifier struct {
 	Token      token.Token // token.IDENT
 	Identifier string
@@ -47,6 +78,7 @@ type Identifier struct {
 // Identifier is an expression to allow `let a = anotherVar`
 func (n *Identifier) expressionNode()      {}
 func (n *Identifier) TokenLiteral() string { return n.Token.Literal }
+func (n *Identifier) Pos() token.Position  { return n.Token.Pos }
 func (n *Identifier) String() string       { return n.Identifier }
 
 // A return statement returns a value
@@ -58,6 +90,7 @@ type ReturnStatement struct {
 
 func (n *ReturnStatement) statementNode()       {}
 func (n *ReturnStatement) TokenLiteral() string { return n.Token.Literal }
+func (n *ReturnStatement) Pos() token.Position  { return n.Token.Pos }
 func (n *ReturnStatement) String() string {
 	var out bytes.Buffer
 
@@ -80,6 +113,7 @@ type ExpressionStatement struct {
 
 func (n *ExpressionStatement) statementNode()       {}
 func (n *ExpressionStatement) TokenLiteral() string { return n.Token.Literal }
+func (n *ExpressionStatement) Pos() token.Position  { return n.Token.Pos }
 func (n *ExpressionStatement) String() string {
 	if n.Expression != nil {
 		return n.Expression.String()
@@ -95,8 +129,19 @@ type IntegerLiteral struct {
 
 func (n *IntegerLiteral) expressionNode()      {}
 func (n *IntegerLiteral) TokenLiteral() string { return n.Token.Literal }
+func (n *IntegerLiteral) Pos() token.Position  { return n.Token.Pos }
 func (n *IntegerLiteral) String() string       { return n.Token.Literal }
 
+type Boolean struct {
+	Token token.Token // Token.TRUE or Token.FALSE
+	Value bool
+}
+
+func (n *Boolean) expressionNode()      {}
+func (n *Boolean) TokenLiteral() string { return n.Token.Literal }
+func (n *Boolean) Pos() token.Position  { return n.Token.Pos }
+func (n *Boolean) String() string       { return n.Token.Literal }
+
 type PrefixExpression struct {
 	Token    token.Token // the operator token, e.g. `+`
 	Operator string
@@ -105,6 +150,7 @@ type PrefixExpression struct {
 
 func (n *PrefixExpression) expressionNode()      {}
 func (n *PrefixExpression) TokenLiteral() string { return n.Token.Literal }
+func (n *PrefixExpression) Pos() token.Position  { return n.Token.Pos }
 func (n *PrefixExpression) String() string {
 	var out bytes.Buffer
 
@@ -126,6 +172,14 @@ type InfixExpression struct {
 
 func (n *InfixExpression) expressionNode()      {}
 func (n *InfixExpression) TokenLiteral() string { return n.Token.Literal }
+func (n *InfixExpression) Pos() token.Position {
+	// the token is the operator, the expression starts with its left operand
+	if n.Left != nil {
+		return n.Left.Pos()
+	}
+
+	return n.Token.Pos
+}
 func (n *InfixExpression) String() string {
 	var out bytes.Buffer
 
@@ -145,3 +199,162 @@ func (n *InfixExpression) String() string {
 
 	return out.String()
 }
+
+// Calls a function with arguments
+// Example: `add(1, 2 * 3)`
+type CallExpression struct {
+	Token     token.Token // token.LPAREN
+	Function  Expression  // an identifier or any expression producing a function
+	Arguments []Expression
+}
+
+func (n *CallExpression) expressionNode()      {}
+func (n *CallExpression) TokenLiteral() string { return n.Token.Literal }
+func (n *CallExpression) Pos() token.Position {
+	// the token is the parenthesis, the call starts with the function
+	if n.Function != nil {
+		return n.Function.Pos()
+	}
+
+	return n.Token.Pos
+}
+func (n *CallExpression) String() string {
+	var out bytes.Buffer
+
+	if n.Function != nil {
+		out.WriteString(n.Function.String())
+	}
+
+	out.WriteString("(" + joinExpressions(n.Arguments) + ")")
+
+	return out.String()
+}
+
+// Calls a function with the receiver as its first argument
+// Example: `list.map(double)`, which is sugar for `map(list, double)`
+type MethodCallExpression struct {
+	Token     token.Token // token.DOT
+	Receiver  Expression
+	Method    *Identifier
+	Arguments []Expression
+}
+
+func (n *MethodCallExpression) expressionNode()      {}
+func (n *MethodCallExpression) TokenLiteral() string { return n.Token.Literal }
+func (n *MethodCallExpression) Pos() token.Position {
+	// the token is the dot, the call starts with the receiver
+	if n.Receiver != nil {
+		return n.Receiver.Pos()
+	}
+
+	return n.Token.Pos
+}
+func (n *MethodCallExpression) String() string {
+	var out bytes.Buffer
+
+	if n.Receiver != nil {
+		out.WriteString(n.Receiver.String())
+	}
+
+	out.WriteString(".")
+
+	if n.Method != nil {
+		out.WriteString(n.Method.String())
+	}
+
+	out.WriteString("(" + joinExpressions(n.Arguments) + ")")
+
+	return out.String()
+}
+
+// Passes the value on the left as the first argument to the call on the right
+// Example: `x |> f(y)`, which is sugar for `f(x, y)`, or `x |> f` for `f(x)`
+type PipeExpression struct {
+	Token token.Token // token.PIPE
+	Left  Expression
+	Right Expression
+}
+
+func (n *PipeExpression) expressionNode()      {}
+func (n *PipeExpression) TokenLiteral() string { return n.Token.Literal }
+func (n *PipeExpression) Pos() token.Position {
+	if n.Left != nil {
+		return n.Left.Pos()
+	}
+
+	return n.Token.Pos
+}
+func (n *PipeExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+
+	if n.Left != nil {
+		out.WriteString(n.Left.String())
+	}
+
+	out.WriteString(" |> ")
+
+	if n.Right != nil {
+		out.WriteString(n.Right.String())
+	}
+
+	out.WriteString(")")
+
+	return out.String()
+}
+
+// Chooses between two values
+// Example: `a > b ? a : b`, only the chosen value is evaluated
+type ConditionalExpression struct {
+	Token       token.Token // token.QUESTION
+	Condition   Expression
+	Consequence Expression
+	Alternative Expression
+}
+
+func (n *ConditionalExpression) expressionNode()      {}
+func (n *ConditionalExpression) TokenLiteral() string { return n.Token.Literal }
+func (n *ConditionalExpression) Pos() token.Position {
+	if n.Condition != nil {
+		return n.Condition.Pos()
+	}
+
+	return n.Token.Pos
+}
+func (n *ConditionalExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+
+	if n.Condition != nil {
+		out.WriteString(n.Condition.String())
+	}
+
+	out.WriteString(" ? ")
+
+	if n.Consequence != nil {
+		out.WriteString(n.Consequence.String())
+	}
+
+	out.WriteString(" : ")
+
+	if n.Alternative != nil {
+		out.WriteString(n.Alternative.String())
+	}
+
+	out.WriteString(")")
+
+	return out.String()
+}
+
+func joinExpressions(expressions []Expression) string {
+	parts := make([]string, len(expressions))
+	for i, expression := range expressions {
+		if expression != nil {
+			parts[i] = expression.String()
+		}
+	}
+
+	return strings.Join(parts, ", ")
+}
@@ -1,7 +1,10 @@
 package ast
 
 import (
+	"fmt"
 	"monkey/token"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -26,3 +29,338 @@ func TestString(t *testing.T) {
 		t.Errorf("program.String() wrong. got=%q", program.String())
 	}
 }
+
+func TestWalk(t *testing.T) {
+	program := &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Name:  &Identifier{Identifier: "x"},
+				Value: &PrefixExpression{Operator: "-", Value: &IntegerLiteral{Value: 1}},
+			},
+			&ReturnStatement{
+				Expression: &InfixExpression{
+					Left:     &Identifier{Identifier: "x"},
+					Operator: "==",
+					Right:    &Boolean{Value: true},
+				},
+			},
+			&ExpressionStatement{},
+		},
+		Comments: []*Comment{{Text: "// c"}},
+	}
+
+	expected := []string{
+		"*ast.Program",
+		"*ast.LetStatement",
+		"*ast.Identifier", "<nil>",
+		"*ast.PrefixExpression",
+		"*ast.IntegerLiteral", "<nil>",
+		"<nil>",
+		"<nil>",
+		"*ast.ReturnStatement",
+		"*ast.InfixExpression",
+		"*ast.Identifier", "<nil>",
+		"*ast.Boolean", "<nil>",
+		"<nil>",
+		"<nil>",
+		"*ast.ExpressionStatement", "<nil>",
+		"*ast.Comment", "<nil>",
+		"<nil>",
+	}
+
+	var visited []string
+	Inspect(program, func(node Node) bool {
+		visited = append(visited, fmt.Sprintf("%T", node))
+		return true
+	})
+
+	if !reflect.DeepEqual(visited, expected) {
+		t.Errorf("wrong traversal.\nexpected=%v\ngot=%v", expected, visited)
+	}
+}
+
+func TestChildren(t *testing.T) {
+	call := &MethodCallExpression{
+		Receiver:  &Identifier{Identifier: "a"},
+		Method:    nil,
+		Arguments: []Expression{&IntegerLiteral{Value: 1}, &Boolean{Value: true}},
+	}
+
+	expected := []string{"Receiver *ast.Identifier", "Arguments[0] *ast.IntegerLiteral", "Arguments[1] *ast.Boolean"}
+
+	var children []string
+	for field, child := range Children(call) {
+		children = append(children, fmt.Sprintf("%s %T", field, child))
+	}
+
+	if !reflect.DeepEqual(children, expected) {
+		t.Errorf("wrong children.\nexpected=%v\ngot=%v", expected, children)
+	}
+}
+
+func TestInspectPrunes(t *testing.T) {
+	program := &Program{
+		Statements: []Statement{
+			&ExpressionStatement{
+				Expression: &InfixExpression{
+					Left:     &Identifier{Identifier: "a"},
+					Operator: "+",
+					Right:    &Identifier{Identifier: "b"},
+				},
+			},
+		},
+	}
+
+	identifiers := 0
+	Inspect(program, func(node Node) bool {
+		if _, ok := node.(*Identifier); ok {
+			identifiers++
+		}
+
+		_, isInfix := node.(*InfixExpression)
+		return !isInfix
+	})
+
+	if identifiers != 0 {
+		t.Errorf("expected the children of the infix expression to be skipped, visited %d identifiers", identifiers)
+	}
+}
+
+func TestModify(t *testing.T) {
+	one := func() Expression { return &IntegerLiteral{Value: 1} }
+	two := func() Expression { return &IntegerLiteral{Value: 2} }
+
+	turnOneIntoTwo := func(node Node) Node {
+		integer, ok := node.(*IntegerLiteral)
+		if !ok || integer.Value != 1 {
+			return node
+		}
+
+		integer.Value = 2
+		return integer
+	}
+
+	testCases := []struct {
+		input    Node
+		expected Node
+	}{
+		{one(), two()},
+		{
+			&Program{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			&Program{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+		},
+		{
+			&InfixExpression{Left: one(), Operator: "+", Right: two()},
+			&InfixExpression{Left: two(), Operator: "+", Right: two()},
+		},
+		{
+			&InfixExpression{Left: two(), Operator: "+", Right: one()},
+			&InfixExpression{Left: two(), Operator: "+", Right: two()},
+		},
+		{
+			&PrefixExpression{Operator: "-", Value: one()},
+			&PrefixExpression{Operator: "-", Value: two()},
+		},
+		{
+			&ReturnStatement{Expression: one()},
+			&ReturnStatement{Expression: two()},
+		},
+		{
+			&LetStatement{Name: &Identifier{Identifier: "x"}, Value: one()},
+			&LetStatement{Name: &Identifier{Identifier: "x"}, Value: two()},
+		},
+	}
+
+	for _, testCase := range testCases {
+		modified := Modify(testCase.input, turnOneIntoTwo)
+
+		if !reflect.DeepEqual(modified, testCase.expected) {
+			t.Errorf("not equal. got=%#v, want=%#v", modified, testCase.expected)
+		}
+	}
+}
+
+func TestModifyReplacesNodes(t *testing.T) {
+	program := &Program{
+		Statements: []Statement{
+			&ExpressionStatement{
+				Expression: &PrefixExpression{Operator: "!", Value: &Boolean{Value: true}},
+			},
+		},
+	}
+
+	Modify(program, func(node Node) Node {
+		if _, ok := node.(*PrefixExpression); ok {
+			return &Boolean{Token: token.Token{Type: token.FALSE, Literal: "false"}}
+		}
+
+		return node
+	})
+
+	if program.String() != "false" {
+		t.Errorf("expected the prefix expression to be replaced. got=%q", program.String())
+	}
+}
+
+func TestModifyWrongReplacement(t *testing.T) {
+	program := &Program{
+		Statements: []Statement{&ExpressionStatement{Expression: &Identifier{Identifier: "x"}}},
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a panic when an expression is replaced by a statement")
+		}
+	}()
+
+	Modify(program, func(node Node) Node {
+		if _, ok := node.(*Identifier); ok {
+			return &ReturnStatement{}
+		}
+
+		return node
+	})
+}
+
+// let x: int = -a + 2; // c
+// return x == true;
+func newTestProgram() *Program {
+	tok := func(tokenType token.TokenType, literal string, offset, line, column int) token.Token {
+		return token.Token{Type: tokenType, Literal: literal, Pos: token.Position{Offset: offset, Line: line, Column: column}}
+	}
+
+	return &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Token: tok(token.LET, "let", 0, 1, 1),
+				Name:  &Identifier{Token: tok(token.IDENT, "x", 4, 1, 5), Identifier: "x"},
+				Type:  &TypeName{Token: tok(token.IDENT, "int", 7, 1, 8), Name: "int"},
+				Value: &InfixExpression{
+					Token: tok(token.PLUS, "+", 16, 1, 17),
+					Left: &PrefixExpression{
+						Token:    tok(token.MINUS, "-", 13, 1, 14),
+						Operator: "-",
+						Value:    &Identifier{Token: tok(token.IDENT, "a", 14, 1, 15), Identifier: "a"},
+					},
+					Operator: "+",
+					Right:    &IntegerLiteral{Token: tok(token.INT, "2", 18, 1, 19), Value: 2},
+				},
+			},
+			&ReturnStatement{
+				Token: tok(token.RETURN, "return", 26, 2, 1),
+				Expression: &InfixExpression{
+					Token:    tok(token.EQ, "==", 35, 2, 10),
+					Left:     &Identifier{Token: tok(token.IDENT, "x", 33, 2, 8), Identifier: "x"},
+					Operator: "==",
+					Right:    &Boolean{Token: tok(token.TRUE, "true", 38, 2, 13), Value: true},
+				},
+			},
+		},
+		Comments: []*Comment{{Token: tok(token.COMMENT, "// c", 21, 1, 22), Text: "// c"}},
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	testCases := []Node{
+		newTestProgram(),
+		&Program{},
+		&ExpressionStatement{Expression: &Identifier{Identifier: "x"}},
+		// missing children, e.g. of a partially parsed program
+		&LetStatement{Name: &Identifier{Identifier: "x"}},
+		&ReturnStatement{},
+		&PipeExpression{
+			Left: &Identifier{Identifier: "x"},
+			Right: &MethodCallExpression{
+				Receiver:  &Identifier{Identifier: "list"},
+				Method:    &Identifier{Identifier: "map"},
+				Arguments: []Expression{&CallExpression{Function: &Identifier{Identifier: "f"}}},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		data, err := MarshalJSON(testCase)
+		if err != nil {
+			t.Fatalf("MarshalJSON(%s) failed: %v", testCase, err)
+		}
+
+		decoded, err := UnmarshalJSON(data)
+		if err != nil {
+			t.Fatalf("UnmarshalJSON(%s) failed: %v", data, err)
+		}
+
+		if !reflect.DeepEqual(decoded, testCase) {
+			t.Errorf("round trip changed the node.\njson=%s\ngot=%#v\nwant=%#v", data, decoded, testCase)
+		}
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	node := &PrefixExpression{
+		Token:    token.Token{Type: token.BANG, Literal: "!", Pos: token.Position{Offset: 0, Line: 1, Column: 1}},
+		Operator: "!",
+		Value:    &Boolean{Token: token.Token{Type: token.TRUE, Literal: "true", Pos: token.Position{Offset: 1, Line: 1, Column: 2}}, Value: true},
+	}
+
+	expected := `{"kind":"PrefixExpression",` +
+		`"token":{"type":"!","literal":"!","pos":{"offset":0,"line":1,"column":1}},` +
+		`"operator":"!",` +
+		`"value":{"kind":"Boolean","token":{"type":"TRUE","literal":"true","pos":{"offset":1,"line":1,"column":2}},"value":true}}`
+
+	data, err := MarshalJSON(node)
+	if err != nil {
+		t.Fatalf("MarshalJSON failed: %v", err)
+	}
+
+	if string(data) != expected {
+		t.Errorf("wrong json.\nexpected=%s\ngot=%s", expected, data)
+	}
+}
+
+func TestUnmarshalJSONErrors(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{`{"token":{}}`, `missing field "kind"`},
+		{`{"kind":"Loop","token":{}}`, "Loop: unknown kind"},
+		{`{"kind":"Identifier"}`, `Identifier: missing field "token"`},
+		{`{"kind":"IntegerLiteral","token":{},"value":"5"}`, `IntegerLiteral: field "value"`},
+		{
+			`{"kind":"ReturnStatement","token":{},"expression":{"kind":"Comment","token":{},"text":"//"}}`,
+			"ReturnStatement: unexpected *ast.Comment, expected ast.Expression",
+		},
+		{`{"kind":"Program","statements":[null],"comments":[]}`, `Program: field "statements": element 0 is null`},
+		{`{"kind":"Program","statements":[],"comments":[null]}`, `Program: field "comments": element 0 is null`},
+		{
+			`{"kind":"CallExpression","token":{},"function":null,"arguments":[null]}`,
+			`CallExpression: field "arguments": element 0 is null`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		_, err := UnmarshalJSON([]byte(testCase.input))
+		if err == nil || !strings.Contains(err.Error(), testCase.expected) {
+			t.Errorf("input %s: expected error containing %q, got=%v", testCase.input, testCase.expected, err)
+		}
+	}
+}
+
+func TestSExpr(t *testing.T) {
+	testCases := []struct {
+		input    Node
+		expected string
+	}{
+		{newTestProgram(), "(program (let (: x int) (+ (- a) 2)) (return (== x true)))"},
+		{&ReturnStatement{}, "(return)"},
+		{&LetStatement{Name: &Identifier{Identifier: "x"}}, "(let x nil)"},
+		{&IntegerLiteral{Value: -5}, "-5"},
+		{&Comment{Text: `// say "hi"`}, `(comment "// say \"hi\"")`},
+	}
+
+	for _, testCase := range testCases {
+		if actual := SExpr(testCase.input); actual != testCase.expected {
+			t.Errorf("expected=%q, got=%q", testCase.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,340 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"monkey/token"
+	"reflect"
+	"strings"
+)
+
+// Encodes the node and its children as JSON, for tools that consume monkey
+// programs without parsing them.
+//
+// Every node is an object with its `kind`, e.g. "InfixExpression", its
+// `token` including the position in the source code, and its fields in
+// lower camel case. Missing children are encoded as null.
+//
+//	{"kind":"PrefixExpression","token":{...},"operator":"-","value":{...}}
+//
+// The program has no token, its `statements` and `comments` are arrays.
+func MarshalJSON(node Node) ([]byte, error) {
+	return json.Marshal(encode(node))
+}
+
+// Decodes a node encoded by MarshalJSON
+func UnmarshalJSON(data []byte) (Node, error) {
+	return decode(data)
+}
+
+// A JSON object whose fields keep their order, so the kind comes first
+type object []field
+
+type field struct {
+	key   string
+	value any
+}
+
+func (o object) MarshalJSON() ([]byte, error) {
+	if o == nil {
+		return []byte("null"), nil
+	}
+
+	var out bytes.Buffer
+
+	out.WriteByte('{')
+	for i, f := range o {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+
+		value, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+
+		fmt.Fprintf(&out, "%q:", f.key)
+		out.Write(value)
+	}
+	out.WriteByte('}')
+
+	return out.Bytes(), nil
+}
+
+func encode(node Node) object {
+	if isNil(node) {
+		return nil
+	}
+
+	kind := strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast.")
+
+	switch n := node.(type) {
+	case *Program:
+		return object{{"kind", kind}, {"statements", encodeList(n.Statements)}, {"comments", encodeList(n.Comments)}}
+
+	// Statements
+	case *LetStatement:
+		return object{{"kind", kind}, {"token", n.Token},
+			{"name", encode(n.Name)}, {"type", encode(n.Type)}, {"value", encode(n.Value)}}
+	case *ReturnStatement:
+		return object{{"kind", kind}, {"token", n.Token}, {"expression", encode(n.Expression)}}
+	case *ExpressionStatement:
+		return object{{"kind", kind}, {"token", n.Token}, {"expression", encode(n.Expression)}}
+
+	// Expressions
+	case *Identifier:
+		return object{{"kind", kind}, {"token", n.Token}, {"identifier", n.Identifier}}
+	case *IntegerLiteral:
+		return object{{"kind", kind}, {"token", n.Token}, {"value", n.Value}}
+	case *Boolean:
+		return object{{"kind", kind}, {"token", n.Token}, {"value", n.Value}}
+	case *PrefixExpression:
+		return object{{"kind", kind}, {"token", n.Token},
+			{"operator", n.Operator}, {"value", encode(n.Value)}}
+	case *InfixExpression:
+		return object{{"kind", kind}, {"token", n.Token},
+			{"left", encode(n.Left)}, {"operator", n.Operator}, {"right", encode(n.Right)}}
+
+	case *CallExpression:
+		return object{{"kind", kind}, {"token", n.Token},
+			{"function", encode(n.Function)}, {"arguments", encodeList(n.Arguments)}}
+	case *MethodCallExpression:
+		return object{{"kind", kind}, {"token", n.Token},
+			{"receiver", encode(n.Receiver)}, {"method", encode(n.Method)}, {"arguments", encodeList(n.Arguments)}}
+	case *PipeExpression:
+		return object{{"kind", kind}, {"token", n.Token},
+			{"left", encode(n.Left)}, {"right", encode(n.Right)}}
+	case *ConditionalExpression:
+		return object{{"kind", kind}, {"token", n.Token}, {"condition", encode(n.Condition)},
+			{"consequence", encode(n.Consequence)}, {"alternative", encode(n.Alternative)}}
+
+	case *Comment:
+		return object{{"kind", kind}, {"token", n.Token}, {"text", n.Text}}
+	case *TypeName:
+		return object{{"kind", kind}, {"token", n.Token}, {"name", n.Name}}
+
+	default:
+		panic(fmt.Sprintf("ast.MarshalJSON: unexpected node type %T", n))
+	}
+}
+
+func encodeList[T Node](nodes []T) []object {
+	list := make([]object, len(nodes))
+	for i, node := range nodes {
+		list[i] = encode(node)
+	}
+
+	return list
+}
+
+// The fields of an encoded node, decoded on demand
+type fields map[string]json.RawMessage
+
+func (f fields) decode(key string, v any) error {
+	raw, ok := f[key]
+	if !ok {
+		return fmt.Errorf("missing field %q", key)
+	}
+
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("field %q: %w", key, err)
+	}
+
+	return nil
+}
+
+func decode(data []byte) (Node, error) {
+	var f fields
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+
+	// null
+	if f == nil {
+		return nil, nil
+	}
+
+	var kind string
+	if err := f.decode("kind", &kind); err != nil {
+		return nil, err
+	}
+
+	if kind == "Program" {
+		return decodeProgram(f)
+	}
+
+	var tok token.Token
+	if err := f.decode("token", &tok); err != nil {
+		return nil, fmt.Errorf("%s: %w", kind, err)
+	}
+
+	node, err := decodeKind(kind, tok, f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", kind, err)
+	}
+
+	return node, nil
+}
+
+func decodeProgram(f fields) (*Program, error) {
+	var err error
+
+	program := &Program{}
+	if program.Statements, err = decodeList[Statement](f, "statements"); err != nil {
+		return nil, fmt.Errorf("Program: %w", err)
+	}
+	if program.Comments, err = decodeList[*Comment](f, "comments"); err != nil {
+		return nil, fmt.Errorf("Program: %w", err)
+	}
+
+	return program, nil
+}
+
+func decodeKind(kind string, tok token.Token, f fields) (Node, error) {
+	var err error
+
+	switch kind {
+	// Statements
+	case "LetStatement":
+		n := &LetStatement{Token: tok}
+		if n.Name, err = decodeField[*Identifier](f, "name"); err != nil {
+			return nil, err
+		}
+		if n.Type, err = decodeField[*TypeName](f, "type"); err != nil {
+			return nil, err
+		}
+		n.Value, err = decodeField[Expression](f, "value")
+		return n, err
+	case "ReturnStatement":
+		n := &ReturnStatement{Token: tok}
+		n.Expression, err = decodeField[Expression](f, "expression")
+		return n, err
+	case "ExpressionStatement":
+		n := &ExpressionStatement{Token: tok}
+		n.Expression, err = decodeField[Expression](f, "expression")
+		return n, err
+
+	// Expressions
+	case "Identifier":
+		n := &Identifier{Token: tok}
+		return n, f.decode("identifier", &n.Identifier)
+	case "IntegerLiteral":
+		n := &IntegerLiteral{Token: tok}
+		return n, f.decode("value", &n.Value)
+	case "Boolean":
+		n := &Boolean{Token: tok}
+		return n, f.decode("value", &n.Value)
+	case "PrefixExpression":
+		n := &PrefixExpression{Token: tok}
+		if err = f.decode("operator", &n.Operator); err != nil {
+			return nil, err
+		}
+		n.Value, err = decodeField[Expression](f, "value")
+		return n, err
+	case "InfixExpression":
+		n := &InfixExpression{Token: tok}
+		if n.Left, err = decodeField[Expression](f, "left"); err != nil {
+			return nil, err
+		}
+		if err = f.decode("operator", &n.Operator); err != nil {
+			return nil, err
+		}
+		n.Right, err = decodeField[Expression](f, "right")
+		return n, err
+
+	case "CallExpression":
+		n := &CallExpression{Token: tok}
+		if n.Function, err = decodeField[Expression](f, "function"); err != nil {
+			return nil, err
+		}
+		n.Arguments, err = decodeList[Expression](f, "arguments")
+		return n, err
+	case "MethodCallExpression":
+		n := &MethodCallExpression{Token: tok}
+		if n.Receiver, err = decodeField[Expression](f, "receiver"); err != nil {
+			return nil, err
+		}
+		if n.Method, err = decodeField[*Identifier](f, "method"); err != nil {
+			return nil, err
+		}
+		n.Arguments, err = decodeList[Expression](f, "arguments")
+		return n, err
+	case "PipeExpression":
+		n := &PipeExpression{Token: tok}
+		if n.Left, err = decodeField[Expression](f, "left"); err != nil {
+			return nil, err
+		}
+		n.Right, err = decodeField[Expression](f, "right")
+		return n, err
+	case "ConditionalExpression":
+		n := &ConditionalExpression{Token: tok}
+		if n.Condition, err = decodeField[Expression](f, "condition"); err != nil {
+			return nil, err
+		}
+		if n.Consequence, err = decodeField[Expression](f, "consequence"); err != nil {
+			return nil, err
+		}
+		n.Alternative, err = decodeField[Expression](f, "alternative")
+		return n, err
+
+	case "Comment":
+		n := &Comment{Token: tok}
+		return n, f.decode("text", &n.Text)
+	case "TypeName":
+		n := &TypeName{Token: tok}
+		return n, f.decode("name", &n.Name)
+	}
+
+	return nil, fmt.Errorf("unknown kind")
+}
+
+func decodeField[T Node](f fields, key string) (T, error) {
+	raw, ok := f[key]
+	if !ok {
+		var zero T
+		return zero, fmt.Errorf("missing field %q", key)
+	}
+
+	return decodeChild[T](raw)
+}
+
+// Decodes an array of child nodes that must be of type T, unlike a single
+// child an element cannot be null
+func decodeList[T Node](f fields, key string) ([]T, error) {
+	var raws []json.RawMessage
+	if err := f.decode(key, &raws); err != nil {
+		return nil, err
+	}
+
+	var list []T
+	for i, raw := range raws {
+		node, err := decodeChild[T](raw)
+		if err != nil {
+			return nil, err
+		}
+		if isNil(node) {
+			return nil, fmt.Errorf("field %q: element %d is null", key, i)
+		}
+		list = append(list, node)
+	}
+
+	return list, nil
+}
+
+// Decodes a child node that must be of type T, null decodes to the zero value
+func decodeChild[T Node](raw json.RawMessage) (T, error) {
+	var zero T
+
+	node, err := decode(raw)
+	if err != nil || node == nil {
+		return zero, err
+	}
+
+	child, ok := node.(T)
+	if !ok {
+		return zero, fmt.Errorf("unexpected %T, expected %v", node, reflect.TypeFor[T]())
+	}
+
+	return child, nil
+}
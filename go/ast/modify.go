@@ -0,0 +1,96 @@
+package ast
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// A function that receives a node and returns its replacement, returning the
+// node itself leaves it unchanged
+type ModifierFunc func(Node) Node
+
+// Rewrites the AST bottom-up: the children of a node are modified before the
+// modifier is called with the node itself. Returns the (possibly replaced)
+// node, the tree is updated in place.
+//
+// A replacement must fit the place of the original node, e.g. an Expression
+// can only be replaced by another Expression.
+func Modify(node Node, modifier ModifierFunc) Node {
+	switch n := node.(type) {
+	case *Program:
+		for i, statement := range n.Statements {
+			n.Statements[i] = modifyChild(statement, modifier)
+		}
+		for i, comment := range n.Comments {
+			n.Comments[i] = modifyChild(comment, modifier)
+		}
+
+	// Statements
+	case *LetStatement:
+		n.Name = modifyChild(n.Name, modifier)
+		n.Type = modifyChild(n.Type, modifier)
+		n.Value = modifyChild(n.Value, modifier)
+	case *ReturnStatement:
+		n.Expression = modifyChild(n.Expression, modifier)
+	case *ExpressionStatement:
+		n.Expression = modifyChild(n.Expression, modifier)
+
+	// Expressions
+	case *Identifier, *IntegerLiteral, *Boolean, *Comment, *TypeName:
+		// leaves, nothing to modify
+	case *PrefixExpression:
+		n.Value = modifyChild(n.Value, modifier)
+	case *InfixExpression:
+		n.Left = modifyChild(n.Left, modifier)
+		n.Right = modifyChild(n.Right, modifier)
+	case *CallExpression:
+		n.Function = modifyChild(n.Function, modifier)
+		for i, argument := range n.Arguments {
+			n.Arguments[i] = modifyChild(argument, modifier)
+		}
+	case *MethodCallExpression:
+		n.Receiver = modifyChild(n.Receiver, modifier)
+		n.Method = modifyChild(n.Method, modifier)
+		for i, argument := range n.Arguments {
+			n.Arguments[i] = modifyChild(argument, modifier)
+		}
+	case *PipeExpression:
+		n.Left = modifyChild(n.Left, modifier)
+		n.Right = modifyChild(n.Right, modifier)
+	case *ConditionalExpression:
+		n.Condition = modifyChild(n.Condition, modifier)
+		n.Consequence = modifyChild(n.Consequence, modifier)
+		n.Alternative = modifyChild(n.Alternative, modifier)
+
+	default:
+		panic(fmt.Sprintf("ast.Modify: unexpected node type %T", n))
+	}
+
+	return modifier(node)
+}
+
+// Modifies the child unless it is nil. Panics if the replacement does not
+// fit the place of the child, e.g. a Statement returned for an Expression.
+func modifyChild[T Node](child T, modifier ModifierFunc) T {
+	if isNil(child) {
+		return child
+	}
+
+	modified := Modify(child, modifier)
+	replacement, ok := modified.(T)
+	if !ok {
+		panic(fmt.Sprintf("ast.Modify: cannot replace %T with %T", child, modified))
+	}
+
+	return replacement
+}
+
+// Reports whether the node is nil, or a nil pointer wrapped in the interface
+func isNil(node Node) bool {
+	if node == nil {
+		return true
+	}
+
+	value := reflect.ValueOf(node)
+	return value.Kind() == reflect.Pointer && value.IsNil()
+}
@@ -1,5 +1,7 @@
 package ast
 
+import "monkey/token"
+
 // Contains the common interfaces for the nodes in the AST
 // Each Node in the AST should implement the Node interface
 //
@@ -13,6 +15,9 @@ type Node interface {
 
 	// Used for printing/debugging AST nodes
 	String() string
+
+	// The position in the source code where the node starts
+	Pos() token.Position
 }
 
 // An expression is a value producing node
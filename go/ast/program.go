@@ -1,6 +1,9 @@
 package ast
 
-import "bytes"
+import (
+	"bytes"
+	"monkey/token"
+)
 
 // The root of every parse tree of the programming language
 // A program contains a series of statements, and is the root of the AST
@@ -12,6 +15,7 @@ import "bytes"
 // └── Statement - `return z;`
 type Program struct {
 	Statements []Statement
+	Comments   []*Comment // all comments of the source code, in order
 }
 
 func (p *Program) TokenLiteral() string {
@@ -22,6 +26,14 @@ func (p *Program) TokenLiteral() string {
 	return ""
 }
 
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+
+	return token.Position{Line: 1, Column: 1}
+}
+
 func (p *Program) String() string {
 	var out bytes.Buffer
 
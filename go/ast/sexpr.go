@@ -0,0 +1,111 @@
+package ast
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+)
+
+// Prints the node as an S-expression, which unlike String() shows the
+// structure of the tree unambiguously
+//
+//	let x: int = -a + 2;   (let (: x int) (+ (- a) 2))
+//	return x == true;      (return (== x true))
+//	a |> f(b.g(c));        (|> a (call f (call (. b g) c)))
+//
+// Expression statements print as their expression, missing children print
+// as `nil`. The program is `(program statement...)`.
+func SExpr(node Node) string {
+	var out bytes.Buffer
+	writeSExpr(&out, node)
+	return out.String()
+}
+
+func writeSExpr(out *bytes.Buffer, node Node) {
+	if isNil(node) {
+		out.WriteString("nil")
+		return
+	}
+
+	list := func(head string, children ...Node) {
+		out.WriteString("(" + head)
+		for _, child := range children {
+			out.WriteByte(' ')
+			writeSExpr(out, child)
+		}
+		out.WriteByte(')')
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		out.WriteString("(program")
+		for _, statement := range n.Statements {
+			out.WriteByte(' ')
+			writeSExpr(out, statement)
+		}
+		out.WriteByte(')')
+
+	// Statements
+	case *LetStatement:
+		out.WriteString("(let ")
+		if n.Type != nil {
+			list(":", n.Name, n.Type)
+		} else {
+			writeSExpr(out, n.Name)
+		}
+		out.WriteByte(' ')
+		writeSExpr(out, n.Value)
+		out.WriteByte(')')
+	case *ReturnStatement:
+		if isNil(n.Expression) {
+			out.WriteString("(return)")
+		} else {
+			list("return", n.Expression)
+		}
+	case *ExpressionStatement:
+		writeSExpr(out, n.Expression)
+
+	// Expressions
+	case *Identifier:
+		out.WriteString(n.Identifier)
+	case *IntegerLiteral:
+		out.WriteString(strconv.FormatInt(n.Value, 10))
+	case *Boolean:
+		out.WriteString(strconv.FormatBool(n.Value))
+	case *PrefixExpression:
+		list(n.Operator, n.Value)
+	case *InfixExpression:
+		list(n.Operator, n.Left, n.Right)
+	case *CallExpression:
+		list("call", append([]Node{n.Function}, nodes(n.Arguments)...)...)
+	case *MethodCallExpression:
+		out.WriteString("(call ")
+		list(".", n.Receiver, n.Method)
+		for _, argument := range n.Arguments {
+			out.WriteByte(' ')
+			writeSExpr(out, argument)
+		}
+		out.WriteByte(')')
+	case *PipeExpression:
+		list("|>", n.Left, n.Right)
+	case *ConditionalExpression:
+		list("?", n.Condition, n.Consequence, n.Alternative)
+
+	case *Comment:
+		out.WriteString("(comment " + strconv.Quote(n.Text) + ")")
+	case *TypeName:
+		out.WriteString(n.Name)
+
+	default:
+		panic(fmt.Sprintf("ast.SExpr: unexpected node type %T", n))
+	}
+}
+
+func nodes[T Node](list []T) []Node {
+	result := make([]Node, len(list))
+	for i, node := range list {
+		result[i] = node
+	}
+
+	return result
+}
@@ -0,0 +1,122 @@
+package ast
+
+import (
+	"fmt"
+	"iter"
+)
+
+// A Visitor's Visit method is invoked for each node encountered by Walk.
+// If the returned visitor w is not nil, Walk visits each of the children of
+// the node with w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Traverses the AST in depth-first order, starting with calling
+// v.Visit(node). Children that are nil, e.g. in a partially parsed program,
+// are skipped.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	for _, child := range Children(node) {
+		Walk(v, child)
+	}
+
+	v.Visit(nil)
+}
+
+// Iterates over the direct children of the node in the order of the fields
+// that hold them, together with the name of the field, e.g. "Left" or
+// "Arguments[1]". Children that are nil, including typed nil pointers such
+// as the *Identifier of a let statement that failed to parse, are skipped.
+func Children(node Node) iter.Seq2[string, Node] {
+	return func(yield func(string, Node) bool) {
+		for _, child := range children(node) {
+			if !yield(child.field, child.node) {
+				return
+			}
+		}
+	}
+}
+
+type child struct {
+	field string
+	node  Node
+}
+
+func children(node Node) []child {
+	var result []child
+	add := func(field string, node Node) {
+		if !isNil(node) {
+			result = append(result, child{field, node})
+		}
+	}
+	addList := func(field string, list []Node) {
+		for i, node := range list {
+			add(fmt.Sprintf("%s[%d]", field, i), node)
+		}
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		addList("Statements", nodes(n.Statements))
+		addList("Comments", nodes(n.Comments))
+
+	// Statements
+	case *LetStatement:
+		add("Name", n.Name)
+		add("Type", n.Type)
+		add("Value", n.Value)
+	case *ReturnStatement:
+		add("Expression", n.Expression)
+	case *ExpressionStatement:
+		add("Expression", n.Expression)
+
+	// Expressions
+	case *Identifier, *IntegerLiteral, *Boolean, *Comment, *TypeName:
+		// leaves, no children
+	case *PrefixExpression:
+		add("Value", n.Value)
+	case *InfixExpression:
+		add("Left", n.Left)
+		add("Right", n.Right)
+	case *CallExpression:
+		add("Function", n.Function)
+		addList("Arguments", nodes(n.Arguments))
+	case *MethodCallExpression:
+		add("Receiver", n.Receiver)
+		add("Method", n.Method)
+		addList("Arguments", nodes(n.Arguments))
+	case *PipeExpression:
+		add("Left", n.Left)
+		add("Right", n.Right)
+	case *ConditionalExpression:
+		add("Condition", n.Condition)
+		add("Consequence", n.Consequence)
+		add("Alternative", n.Alternative)
+
+	default:
+		panic(fmt.Sprintf("ast.Children: unexpected node type %T", n))
+	}
+
+	return result
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+
+	return nil
+}
+
+// Traverses the AST in depth-first order, starting with calling f(node).
+// If f returns true, Inspect is called recursively for each of the children
+// of node, followed by a call of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
@@ -0,0 +1,129 @@
+// Package astviz renders the AST of a monkey program for humans, as a
+// Graphviz graph or as an HTML page of collapsible nodes.
+//
+// Both show every node with its kind and literal, and the name of the field
+// through which its parent refers to it, which makes it easy to see how the
+// precedence of the operators shapes the tree, e.g. for `1 + 2 * 3`:
+//
+//	InfixExpression +
+//	├── Left: IntegerLiteral 1
+//	└── Right: InfixExpression *
+//	    ├── Left: IntegerLiteral 2
+//	    └── Right: IntegerLiteral 3
+package astviz
+
+import (
+	"bufio"
+	"fmt"
+	"html/template"
+	"io"
+	"monkey/ast"
+	"strings"
+)
+
+func kind(node ast.Node) string {
+	return strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast.")
+}
+
+// The literal of the token of the node, empty for the program
+func literal(node ast.Node) string {
+	if _, ok := node.(*ast.Program); ok {
+		return ""
+	}
+
+	return node.TokenLiteral()
+}
+
+// Writes the tree as a Graphviz DOT graph, e.g. for `dot -Tsvg`
+func Dot(w io.Writer, node ast.Node) error {
+	out := bufio.NewWriter(w)
+
+	fmt.Fprintln(out, "digraph AST {")
+	fmt.Fprintln(out, `	node [shape=box, fontname="monospace"];`)
+	fmt.Fprintln(out, `	edge [fontname="monospace", fontsize=10];`)
+
+	id := 0
+	var visit func(node ast.Node) int
+	visit = func(node ast.Node) int {
+		self := id
+		id++
+
+		label := dotEscape(kind(node))
+		if literal := literal(node); literal != "" {
+			label += `\n` + dotEscape(literal)
+		}
+		fmt.Fprintf(out, "\tn%d [label=\"%s\"];\n", self, label)
+
+		for field, child := range ast.Children(node) {
+			childID := visit(child)
+			fmt.Fprintf(out, "\tn%d -> n%d [label=\"%s\"];\n", self, childID, dotEscape(field))
+		}
+
+		return self
+	}
+	visit(node)
+
+	fmt.Fprintln(out, "}")
+
+	return out.Flush()
+}
+
+// Escapes the text for a double quoted DOT string
+func dotEscape(text string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(text)
+}
+
+// A node as rendered by the HTML template
+type htmlNode struct {
+	Field    string
+	Kind     string
+	Literal  string
+	Pos      string
+	Children []htmlNode
+}
+
+func newHTMLNode(field string, node ast.Node) htmlNode {
+	n := htmlNode{Field: field, Kind: kind(node), Literal: literal(node), Pos: node.Pos().String()}
+
+	for field, child := range ast.Children(node) {
+		n.Children = append(n.Children, newHTMLNode(field, child))
+	}
+
+	return n
+}
+
+var page = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>AST</title>
+<style>
+body { font-family: monospace; }
+details, .leaf { margin-left: 1.5em; }
+summary { cursor: pointer; }
+.field { color: #888; }
+.kind { font-weight: bold; }
+.literal { color: #a31515; }
+.pos { color: #888; font-size: smaller; }
+</style>
+</head>
+<body>
+{{template "node" .}}
+</body>
+</html>
+{{define "label"}}{{with .Field}}<span class="field">{{.}}</span> {{end -}}
+<span class="kind">{{.Kind}}</span>
+{{- with .Literal}} <span class="literal">{{.}}</span>{{end}} <span class="pos">{{.Pos}}</span>{{end}}
+{{define "node"}}{{if .Children -}}
+<details open><summary>{{template "label" .}}</summary>
+{{range .Children}}{{template "node" .}}{{end -}}
+</details>
+{{else -}}
+<div class="leaf">{{template "label" .}}</div>
+{{end}}{{end}}`))
+
+// Writes the tree as a standalone HTML page, in which every node with
+// children can be collapsed
+func HTML(w io.Writer, node ast.Node) error {
+	return page.Execute(w, newHTMLNode("", node))
+}
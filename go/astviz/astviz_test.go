@@ -0,0 +1,64 @@
+package astviz
+
+import (
+	"bytes"
+	"monkey/lexer"
+	"monkey/parser"
+	"strings"
+	"testing"
+)
+
+func TestDot(t *testing.T) {
+	p := parser.New(lexer.New(`-a * "`))
+	program := p.ParseProgram()
+
+	var out bytes.Buffer
+	if err := Dot(&out, program); err != nil {
+		t.Fatalf("Dot failed: %v", err)
+	}
+
+	// the right operand failed to parse, missing children are left out
+	expected := `digraph AST {
+	node [shape=box, fontname="monospace"];
+	edge [fontname="monospace", fontsize=10];
+	n0 [label="Program"];
+	n1 [label="ExpressionStatement\n-"];
+	n2 [label="InfixExpression\n*"];
+	n3 [label="PrefixExpression\n-"];
+	n4 [label="Identifier\na"];
+	n3 -> n4 [label="Value"];
+	n2 -> n3 [label="Left"];
+	n1 -> n2 [label="Expression"];
+	n0 -> n1 [label="Statements[0]"];
+}
+`
+
+	if out.String() != expected {
+		t.Errorf("wrong graph.\nexpected=%s\ngot=%s", expected, out.String())
+	}
+}
+
+func TestHTML(t *testing.T) {
+	p := parser.New(lexer.New("let x = 1 < 2;"))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	var out bytes.Buffer
+	if err := HTML(&out, program); err != nil {
+		t.Fatalf("HTML failed: %v", err)
+	}
+
+	expected := []string{
+		`<details open><summary><span class="kind">Program</span> <span class="pos">1:1</span></summary>`,
+		`<details open><summary><span class="field">Value</span> <span class="kind">InfixExpression</span> <span class="literal">&lt;</span> <span class="pos">1:9</span></summary>`,
+		`<div class="leaf"><span class="field">Right</span> <span class="kind">IntegerLiteral</span> <span class="literal">2</span> <span class="pos">1:13</span></div>`,
+	}
+
+	for _, e := range expected {
+		if !strings.Contains(out.String(), e) {
+			t.Errorf("expected the page to contain %s\ngot=%s", e, out.String())
+		}
+	}
+}
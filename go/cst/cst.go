@@ -0,0 +1,276 @@
+// Package cst implements a lossless concrete syntax tree for the monkey
+// programming language.
+//
+// The AST drops everything that does not influence the meaning of a
+// program: whitespace, comments, parenthesis and semicolons. The concrete
+// syntax tree keeps all of it, such that printing the tree reproduces the
+// source code byte for byte:
+//
+//	Print(Parse(source)) == source
+//
+// Every AST node becomes a Node holding its child nodes and its own tokens,
+// in source order. Whitespace and comments are attached to the tokens as
+// trivia: a token owns the trivia that follows it up to the end of its line
+// (trailing), and everything after that belongs to the next token (leading).
+// Whatever follows the last token ends up as leading trivia of EOF.
+//
+// Refactoring tools can therefore change a single node and print the tree,
+// leaving the formatting of the rest of the file untouched.
+package cst
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/token"
+	"sort"
+	"strings"
+)
+
+type TriviaKind int
+
+const (
+	Whitespace TriviaKind = iota
+	Comment
+)
+
+// Source code between tokens that does not affect the program
+type Trivia struct {
+	Kind TriviaKind
+	Text string
+}
+
+// An element of a node: either a *Node or a *Token
+type Element interface {
+	element()
+}
+
+// A token together with the trivia that surrounds it
+type Token struct {
+	token.Token
+	Leading  []Trivia
+	Trailing []Trivia
+}
+
+// A node of the tree, corresponding to a node of the AST
+type Node struct {
+	AST      ast.Node // the AST node, *ast.Program for the root
+	Span     parser.Span
+	Children []Element // child nodes and tokens, in source order
+}
+
+func (*Token) element() {}
+func (*Node) element()  {}
+
+// The kind of node, the type name of the AST node, e.g. "LetStatement"
+func (n *Node) Kind() string {
+	return strings.TrimPrefix(fmt.Sprintf("%T", n.AST), "*ast.")
+}
+
+// Parses the source code into a concrete syntax tree
+// A tree is returned even when there are parse errors, tokens that are not
+// part of any parsed node are attached to the root.
+func Parse(source string) (*Node, []*parser.Error) {
+	tokens := tokenize(source)
+
+	p := parser.NewFromStream(lexer.FromTokens(tokens))
+	p.TrackSpans()
+	program := p.ParseProgram()
+
+	b := &builder{spans: p.Spans(), tokens: attachTrivia(source, tokens)}
+
+	root := b.build(program, parser.Span{
+		Start: token.Position{Offset: 0, Line: 1, Column: 1},
+		End:   token.Position{Offset: len(source) + 1},
+	})
+
+	return root, p.ErrorList()
+}
+
+// Prints the tree, reproducing the source code it was parsed from
+func Print(tree *Node) string {
+	var out strings.Builder
+	printNode(&out, tree)
+
+	return out.String()
+}
+
+func printNode(out *strings.Builder, node *Node) {
+	for _, child := range node.Children {
+		switch child := child.(type) {
+		case *Node:
+			printNode(out, child)
+		case *Token:
+			for _, trivia := range child.Leading {
+				out.WriteString(trivia.Text)
+			}
+			out.WriteString(child.Literal)
+			for _, trivia := range child.Trailing {
+				out.WriteString(trivia.Text)
+			}
+		}
+	}
+}
+
+// Returns all tokens of the source code, including comments, up to and
+// including EOF
+func tokenize(source string) []token.Token {
+	var tokens []token.Token
+
+	l := lexer.New(source)
+	for {
+		tok := l.NextToken()
+		tokens = append(tokens, tok)
+
+		if tok.Type == token.EOF {
+			return tokens
+		}
+	}
+}
+
+// Turns the comments and the whitespace between the tokens into trivia of
+// the remaining tokens
+func attachTrivia(source string, tokens []token.Token) []*Token {
+	var result []*Token
+	var pending []Trivia // trivia since the previous token
+	end := 0             // offset just after the previous token
+
+	for _, tok := range tokens {
+		gap := source[end:tok.Pos.Offset]
+		if tok.Type == token.EOF {
+			// the lexer stops at a NUL byte, keep whatever follows it
+			gap = source[end:]
+		}
+
+		if gap != "" {
+			pending = append(pending, Trivia{Kind: Whitespace, Text: gap})
+		}
+
+		if tok.Type == token.COMMENT {
+			pending = append(pending, Trivia{Kind: Comment, Text: tok.Literal})
+			end = tok.Pos.Offset + len(tok.Literal)
+			continue
+		}
+
+		current := &Token{Token: tok}
+		if len(result) > 0 {
+			previous := result[len(result)-1]
+			previous.Trailing, current.Leading = splitAtNewline(pending)
+		} else {
+			current.Leading = pending
+		}
+
+		result = append(result, current)
+		pending = nil
+		end = tok.Pos.Offset + len(tok.Literal)
+	}
+
+	return result
+}
+
+// Splits trivia at the first newline, the newline itself starts the second
+// half
+func splitAtNewline(trivia []Trivia) ([]Trivia, []Trivia) {
+	for i, t := range trivia {
+		if t.Kind != Whitespace {
+			continue
+		}
+
+		newline := strings.IndexByte(t.Text, '\n')
+		if newline == -1 {
+			continue
+		}
+
+		trailing := append([]Trivia(nil), trivia[:i]...)
+		if newline > 0 {
+			trailing = append(trailing, Trivia{Kind: Whitespace, Text: t.Text[:newline]})
+		}
+
+		leading := []Trivia{{Kind: Whitespace, Text: t.Text[newline:]}}
+		leading = append(leading, trivia[i+1:]...)
+
+		return trailing, leading
+	}
+
+	return trivia, nil
+}
+
+type builder struct {
+	spans  map[ast.Node]parser.Span
+	tokens []*Token // the significant tokens, in source order
+}
+
+// Builds the node for an AST node covering the span. Tokens within the span
+// that are not covered by one of the child nodes belong to this node.
+func (b *builder) build(node ast.Node, span parser.Span) *Node {
+	result := &Node{AST: node, Span: span}
+
+	var children []*Node
+	for _, child := range astChildren(node) {
+		children = append(children, b.build(child, b.span(child)))
+	}
+
+	first := sort.Search(len(b.tokens), func(i int) bool {
+		return b.tokens[i].Pos.Offset >= span.Start.Offset
+	})
+
+	for _, tok := range b.tokens[first:] {
+		if tok.Pos.Offset >= span.End.Offset {
+			break
+		}
+
+		// skip tokens owned by a child, adding the child in their place
+		covered := false
+		for len(children) > 0 && children[0].Span.Start.Offset <= tok.Pos.Offset {
+			if tok.Pos.Offset < children[0].Span.End.Offset {
+				covered = true
+				break
+			}
+
+			result.Children = append(result.Children, children[0])
+			children = children[1:]
+		}
+
+		if !covered {
+			result.Children = append(result.Children, tok)
+		}
+	}
+
+	for _, child := range children {
+		result.Children = append(result.Children, child)
+	}
+
+	return result
+}
+
+// The span of a node as recorded by the parser, nodes that are not parsed as
+// statements or expressions, such as the name of a let statement, consist of
+// a single token
+func (b *builder) span(node ast.Node) parser.Span {
+	if span, ok := b.spans[node]; ok {
+		return span
+	}
+
+	start := node.Pos()
+	length := len(node.TokenLiteral())
+
+	return parser.Span{
+		Start: start,
+		End:   token.Position{Offset: start.Offset + length, Line: start.Line, Column: start.Column + length},
+	}
+}
+
+// Returns the direct children of an AST node, apart from the comments of
+// the program, which are trivia of the tokens
+func astChildren(node ast.Node) []ast.Node {
+	var children []ast.Node
+
+	for _, child := range ast.Children(node) {
+		if _, ok := child.(*ast.Comment); !ok {
+			children = append(children, child)
+		}
+	}
+
+	return children
+}
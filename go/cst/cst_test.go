@@ -0,0 +1,85 @@
+package cst
+
+import (
+	"monkey/ast"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	inputs := []string{
+		"",
+		"let x = 5;",
+		"  let   x   =   5  ;  ",
+		"// header comment\n\nlet x = (1 + 2) * 3; // trailing\n\n\treturn x;\n// footer",
+		"let typed : int = - 5 ;\n\n\n",
+		"a + b * c\n(a + b) * c\n!-((a))",
+		// syntax errors and characters the lexer does not know
+		"let = 5; let x = ;\r\n@ é",
+		"x; \x00 after a NUL byte",
+	}
+
+	for _, input := range inputs {
+		tree, _ := Parse(input)
+
+		if actual := Print(tree); actual != input {
+			t.Errorf("round trip failed.\nexpected=%q\ngot=%q", input, actual)
+		}
+	}
+}
+
+func TestTree(t *testing.T) {
+	tree, errors := Parse("let x = (1 + 2); // three\nx")
+	if len(errors) > 0 {
+		t.Fatalf("unexpected parse errors: %v", errors)
+	}
+
+	if tree.Kind() != "Program" || len(tree.Children) != 3 {
+		t.Fatalf("expected a program with two statements and EOF, got=%s with %d children", tree.Kind(), len(tree.Children))
+	}
+
+	let, ok := tree.Children[0].(*Node)
+	if !ok || let.Kind() != "LetStatement" {
+		t.Fatalf("expected a LetStatement, got=%#v", tree.Children[0])
+	}
+
+	// let, name, =, value, ;
+	var kinds []string
+	for _, child := range let.Children {
+		switch child := child.(type) {
+		case *Node:
+			kinds = append(kinds, child.Kind())
+		case *Token:
+			kinds = append(kinds, child.Literal)
+		}
+	}
+
+	expected := []string{"let", "Identifier", "=", "InfixExpression", ";"}
+	if len(kinds) != len(expected) {
+		t.Fatalf("expected children %q, got=%q", expected, kinds)
+	}
+	for i := range expected {
+		if kinds[i] != expected[i] {
+			t.Fatalf("expected children %q, got=%q", expected, kinds)
+		}
+	}
+
+	// the parenthesis belong to the grouped expression
+	infix := let.Children[3].(*Node)
+	if _, ok := infix.AST.(*ast.InfixExpression); !ok {
+		t.Fatalf("expected the node to wrap an *ast.InfixExpression, got=%T", infix.AST)
+	}
+	if open := infix.Children[0].(*Token); open.Literal != "(" {
+		t.Errorf("expected the grouped expression to start with (, got=%q", open.Literal)
+	}
+
+	// the comment trails the semicolon, the newline leads the next statement
+	semicolon := let.Children[4].(*Token)
+	if len(semicolon.Trailing) != 2 || semicolon.Trailing[1].Kind != Comment || semicolon.Trailing[1].Text != "// three" {
+		t.Errorf("expected the comment to trail the semicolon, got=%+v", semicolon.Trailing)
+	}
+
+	x := tree.Children[1].(*Node).Children[0].(*Node).Children[0].(*Token)
+	if len(x.Leading) != 1 || x.Leading[0].Text != "\n" {
+		t.Errorf("expected the newline to lead the next statement, got=%+v", x.Leading)
+	}
+}
@@ -0,0 +1,92 @@
+// Package desugar rewrites the syntactic sugar of the monkey programming
+// language into the core constructs it stands for, such that later stages
+// such as code generation only need to handle the core language:
+// `x |> f(y)` becomes `f(x, y)`
+// `x |> f` becomes `f(x)`
+// `list.map(double)` becomes `map(list, double)`
+//
+// Method calls resolve to whatever function the method name refers to where
+// the call appears, typically a builtin function.
+package desugar
+
+import (
+	"monkey/ast"
+	"monkey/token"
+)
+
+// Rewrites all pipes and method calls of the program into plain calls, in
+// place
+func Desugar(program *ast.Program) *ast.Program {
+	// Modify rewrites the right side of a pipe before the pipe itself, so
+	// the pipes whose right side is a pipe in the source are found first:
+	// the call that right side becomes is a value, not a call to extend
+	values := map[*ast.PipeExpression]bool{}
+	ast.Inspect(program, func(node ast.Node) bool {
+		if pipe, ok := node.(*ast.PipeExpression); ok {
+			_, values[pipe] = pipe.Right.(*ast.PipeExpression)
+		}
+		return true
+	})
+
+	// pipes first, such that `x |> list.map(f)` becomes `list.map(x, f)`
+	// and then `map(list, x, f)`, the receiver stays the first argument
+	ast.Modify(program, func(node ast.Node) ast.Node {
+		if pipe, ok := node.(*ast.PipeExpression); ok {
+			return desugarPipe(pipe, values[pipe])
+		}
+
+		return node
+	})
+
+	ast.Modify(program, func(node ast.Node) ast.Node {
+		if call, ok := node.(*ast.MethodCallExpression); ok {
+			return desugarMethodCall(call)
+		}
+
+		return node
+	})
+
+	return program
+}
+
+// Prepends the left side of the pipe to the arguments of the call on its
+// right side, or calls the right side when it is not a call or when it is
+// a value, e.g. `x |> (y |> f)` is `f(y)(x)`
+func desugarPipe(pipe *ast.PipeExpression, value bool) ast.Expression {
+	if value {
+		return callRight(pipe)
+	}
+
+	switch right := pipe.Right.(type) {
+	case *ast.CallExpression:
+		right.Arguments = prepend(pipe.Left, right.Arguments)
+		return right
+	case *ast.MethodCallExpression:
+		right.Arguments = prepend(pipe.Left, right.Arguments)
+		return right
+	}
+
+	return callRight(pipe)
+}
+
+// Calls the right side of the pipe with the left side
+func callRight(pipe *ast.PipeExpression) ast.Expression {
+	return &ast.CallExpression{
+		Token:     token.Token{Type: token.LPAREN, Literal: "(", Pos: pipe.Token.Pos},
+		Function:  pipe.Right,
+		Arguments: []ast.Expression{pipe.Left},
+	}
+}
+
+// Calls the method with the receiver as the first argument
+func desugarMethodCall(call *ast.MethodCallExpression) ast.Expression {
+	return &ast.CallExpression{
+		Token:     token.Token{Type: token.LPAREN, Literal: "(", Pos: call.Token.Pos},
+		Function:  call.Method,
+		Arguments: prepend(call.Receiver, call.Arguments),
+	}
+}
+
+func prepend(first ast.Expression, rest []ast.Expression) []ast.Expression {
+	return append([]ast.Expression{first}, rest...)
+}
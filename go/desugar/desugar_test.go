@@ -0,0 +1,46 @@
+package desugar
+
+import (
+	"monkey/lexer"
+	"monkey/parser"
+	"testing"
+)
+
+func TestDesugar(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"x |> f", "f(x)"},
+		{"x |> f(y)", "f(x, y)"},
+		{"x |> f |> g(1)", "g(f(x), 1)"},
+		// a pipe on the right is a value that is called
+		{"x |> (y |> f)", "f(y)(x)"},
+		{"x |> (f |> g)", "g(f)(x)"},
+		{"x |> (y |> f(z))", "f(y, z)(x)"},
+		{"a + b |> f", "f((a + b))"},
+		{"n * 2 |> show", "show((n * 2))"},
+		{"x |> f == 2", "(f(x) == 2)"},
+		{"x |> f(y) + 1", "(f(x, y) + 1)"},
+		{"list.map(double)", "map(list, double)"},
+		{"list.filter(odd).map(double)", "map(filter(list, odd), double)"},
+		{"x |> list.map(f)", "map(list, x, f)"},
+		{"f(a.len(), b |> g)", "f(len(a), g(b))"},
+		{"let y = x.abs();", "let y = abs(x);"},
+		// no sugar, nothing changes
+		{"f(x) + 1", "(f(x) + 1)"},
+	}
+
+	for _, testCase := range testCases {
+		p := parser.New(lexer.New(testCase.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			t.Fatalf("input %q: parser errors: %v", testCase.input, p.Errors())
+		}
+
+		actual := Desugar(program).String()
+		if actual != testCase.expected {
+			t.Errorf("input %q: expected=%q, got=%q", testCase.input, testCase.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+// Package format implements the canonical formatting of monkey source code.
+//
+// Unlike the String() methods of the AST nodes, which exist for debugging,
+// the formatter produces source code that is meant to be read and checked
+// in:
+// - one statement per line, each terminated by a semicolon
+// - a single space around infix operators, none after prefix operators
+// - parenthesis only where the precedence of the operators requires them
+// - comments are kept, as are single blank lines between statements
+//
+// Formatting is idempotent, formatting already formatted code is a no-op.
+package format
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/token"
+	"strings"
+)
+
+// Formats the source code of a program
+// Source code that does not parse is returned as an error
+func Source(source []byte) ([]byte, error) {
+	p := parser.New(lexer.New(string(source)))
+	program := p.ParseProgram()
+
+	if len(p.Errors()) > 0 {
+		return nil, errors.New(strings.Join(p.Errors(), "\n"))
+	}
+
+	var out bytes.Buffer
+	if err := Fprint(&out, program); err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
+
+// Writes the formatted program, including its comments, to w
+func Fprint(w io.Writer, program *ast.Program) error {
+	p := &printer{}
+
+	comments := program.Comments
+	for _, statement := range program.Statements {
+		// comments that appear before the statement, or within it since the
+		// statement is printed on a single line, go before the statement
+		end := lastPosition(statement)
+		for len(comments) > 0 && comments[0].Pos().Offset < end.Offset {
+			p.comment(comments[0])
+			comments = comments[1:]
+		}
+
+		p.separate(statement.Pos().Line)
+		p.statement(statement)
+		p.lastLine = max(p.lastLine, end.Line)
+	}
+
+	for _, comment := range comments {
+		p.comment(comment)
+	}
+
+	if p.out.Len() > 0 {
+		p.out.WriteByte('\n')
+	}
+
+	_, err := w.Write(p.out.Bytes())
+	return err
+}
+
+type printer struct {
+	out bytes.Buffer
+
+	// The source line on which the last printed statement or comment ended
+	// Zero when nothing has been printed yet
+	lastLine int
+}
+
+// Starts a new line for something that starts at the given source line
+// A blank line is kept when the source separates them by one or more
+func (p *printer) separate(line int) {
+	if p.lastLine == 0 {
+		return
+	}
+
+	p.out.WriteByte('\n')
+	if line > p.lastLine+1 {
+		p.out.WriteByte('\n')
+	}
+}
+
+// Comments on the line on which the previous statement ended stay there,
+// other comments are printed on their own line
+func (p *printer) comment(comment *ast.Comment) {
+	if p.lastLine != 0 && comment.Pos().Line == p.lastLine {
+		p.out.WriteByte(' ')
+	} else {
+		p.separate(comment.Pos().Line)
+	}
+
+	p.out.WriteString(strings.TrimRight(comment.Text, " \t\r"))
+	p.lastLine = max(p.lastLine, comment.Pos().Line)
+}
+
+func (p *printer) statement(statement ast.Statement) {
+	switch statement := statement.(type) {
+	case *ast.LetStatement:
+		p.out.WriteString("let ")
+		p.out.WriteString(statement.Name.Identifier)
+		if statement.Type != nil {
+			p.out.WriteString(": " + statement.Type.Name)
+		}
+		p.out.WriteString(" = ")
+		p.expression(statement.Value, parser.LOWEST)
+	case *ast.ReturnStatement:
+		p.out.WriteString("return")
+		if statement.Expression != nil {
+			p.out.WriteByte(' ')
+			p.expression(statement.Expression, parser.LOWEST)
+		}
+	case *ast.ExpressionStatement:
+		p.expression(statement.Expression, parser.LOWEST)
+	}
+
+	p.out.WriteByte(';')
+}
+
+// Prints the expression, wrapped in parenthesis when it binds less tightly
+// than the surrounding expression requires
+func (p *printer) expression(expression ast.Expression, outer int) {
+	// missing in a program with parse errors, e.g. the value of `let x = ;`
+	if expression == nil {
+		return
+	}
+
+	level := precedence(expression)
+	if level < outer {
+		p.out.WriteByte('(')
+		defer p.out.WriteByte(')')
+	}
+
+	switch expression := expression.(type) {
+	case *ast.PrefixExpression:
+		p.out.WriteString(expression.Operator)
+		p.expression(expression.Value, parser.PREFIX)
+	case *ast.InfixExpression:
+		// an operand with the same precedence on the side the operator does
+		// not group to needs parenthesis: `a - (b - c)` and `(a ** b) ** c`
+		left, right := level, level+1
+		if parser.IsRightAssociative(expression.Token.Type) {
+			left, right = level+1, level
+		}
+		// while a prefix operand to the right takes everything that binds
+		// tighter than the prefix operator: `2 ** -1` and `a ** -b ** c`
+		if precedence(expression.Right) == parser.PREFIX {
+			right = min(right, parser.PREFIX)
+		}
+
+		p.expression(expression.Left, left)
+		p.out.WriteString(" " + expression.Operator + " ")
+		p.expression(expression.Right, right)
+	case *ast.PipeExpression:
+		p.expression(expression.Left, level)
+		p.out.WriteString(" |> ")
+		p.expression(expression.Right, parser.CALL)
+	case *ast.ConditionalExpression:
+		p.expression(expression.Condition, level+1)
+		p.out.WriteString(" ? ")
+		p.expression(expression.Consequence, parser.LOWEST)
+		p.out.WriteString(" : ")
+		p.expression(expression.Alternative, level)
+	case *ast.CallExpression:
+		p.expression(expression.Function, parser.CALL)
+		p.arguments(expression.Arguments)
+	case *ast.MethodCallExpression:
+		p.expression(expression.Receiver, parser.CALL)
+		p.out.WriteString("." + expression.Method.Identifier)
+		p.arguments(expression.Arguments)
+	default:
+		p.out.WriteString(expression.String())
+	}
+}
+
+func (p *printer) arguments(arguments []ast.Expression) {
+	p.out.WriteByte('(')
+	for i, argument := range arguments {
+		if i > 0 {
+			p.out.WriteString(", ")
+		}
+		p.expression(argument, parser.LOWEST)
+	}
+	p.out.WriteByte(')')
+}
+
+// The precedence level of the operator at the root of the expression
+func precedence(expression ast.Expression) int {
+	switch expression := expression.(type) {
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	case *ast.InfixExpression:
+		return parser.Precedence(expression.Token.Type)
+	case *ast.PipeExpression:
+		return parser.PIPE
+	case *ast.ConditionalExpression:
+		return parser.CONDITIONAL
+	case *ast.IntegerLiteral:
+		// negative literals, e.g. produced by constant folding, print with
+		// a minus sign and therefore behave like a prefix expression
+		if expression.Value < 0 {
+			return parser.PREFIX
+		}
+	}
+
+	return parser.CALL
+}
+
+// The position of the last token of the node that is part of the AST
+func lastPosition(node ast.Node) token.Position {
+	var last token.Position
+
+	ast.Inspect(node, func(n ast.Node) bool {
+		if n != nil && n.Pos().Offset >= last.Offset {
+			last = n.Pos()
+		}
+		return true
+	})
+
+	return last
+}
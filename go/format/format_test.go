@@ -0,0 +1,91 @@
+package format
+
+import "testing"
+
+func TestSource(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"let x=5", "let x = 5;\n"},
+		{"let x = 5; let y = 10;", "let x = 5;\nlet y = 10;\n"},
+		{"return   x", "return x;\n"},
+		{"a+b*c", "a + b * c;\n"},
+		{"(a+b)*c", "(a + b) * c;\n"},
+		{"((a*b))+c", "a * b + c;\n"},
+		{"a-(b-c)", "a - (b - c);\n"},
+		{"(a-b)-c", "a - b - c;\n"},
+		{"-(a+b)", "-(a + b);\n"},
+		{"!-a", "!-a;\n"},
+		{"let  x :int=5", "let x: int = 5;\n"},
+		{"(5 > 4) == (3 < 4)", "5 > 4 == 3 < 4;\n"},
+		{"a == (b == c)", "a == (b == c);\n"},
+		{"f( a,b+1 )", "f(a, b + 1);\n"},
+		{"(-a).abs( )", "(-a).abs();\n"},
+		{"x|>f(y)|>g", "x |> f(y) |> g;\n"},
+		{"x |> (f |> g)", "x |> (f |> g);\n"},
+		{"(a + b) |> f", "a + b |> f;\n"},
+		{"a + (b |> f)", "a + (b |> f);\n"},
+		{"x |> f(y) + 1", "(x |> f(y)) + 1;\n"},
+		{"2**(3**2)", "2 ** 3 ** 2;\n"},
+		{"(2**3)**2", "(2 ** 3) ** 2;\n"},
+		{"-(a**2)", "-a ** 2;\n"},
+		{"(-a)**2", "(-a) ** 2;\n"},
+		{"2 ** -1", "2 ** -1;\n"},
+		{"a ** -b", "a ** -b;\n"},
+		{"a ** -(b ** c)", "a ** -b ** c;\n"},
+		{"a ** (-b) ** c", "a ** (-b) ** c;\n"},
+		{"a?b:(c?d:e)", "a ? b : c ? d : e;\n"},
+		{"(a?b:c)?d:e", "(a ? b : c) ? d : e;\n"},
+		{"a?(b?c:d):e", "a ? b ? c : d : e;\n"},
+		{"(x |> f) ? 1 : 2", "x |> f ? 1 : 2;\n"},
+		{"", ""},
+		{
+			"let x = 1;\n\n\n\nlet y = 2;\nx;",
+			"let x = 1;\n\nlet y = 2;\nx;\n",
+		},
+		{
+			"// header\n\nlet x = 1;   // the answer   \n// about y\nlet y = 2;\n// end",
+			"// header\n\nlet x = 1; // the answer\n// about y\nlet y = 2;\n// end\n",
+		},
+		{
+			// the statement is printed on one line, its comments go first
+			"let x = 1 +\n// c\n2;\nlet y = 3;",
+			"// c\nlet x = 1 + 2;\nlet y = 3;\n",
+		},
+		{
+			"a;\n\nlet x = 1 + // c\n2;\n\nlet y = 3;",
+			"a;\n\n// c\nlet x = 1 + 2;\n\nlet y = 3;\n",
+		},
+		{
+			"// only a comment",
+			"// only a comment\n",
+		},
+	}
+
+	for _, testCase := range testCases {
+		actual, err := Source([]byte(testCase.input))
+		if err != nil {
+			t.Fatalf("input %q: unexpected error: %v", testCase.input, err)
+		}
+
+		if string(actual) != testCase.expected {
+			t.Errorf("input %q: expected=%q, got=%q", testCase.input, testCase.expected, actual)
+		}
+
+		again, err := Source(actual)
+		if err != nil {
+			t.Fatalf("input %q: formatted source does not parse: %v", testCase.input, err)
+		}
+
+		if string(again) != string(actual) {
+			t.Errorf("input %q: formatting is not idempotent. first=%q, second=%q", testCase.input, actual, again)
+		}
+	}
+}
+
+func TestSourceParseError(t *testing.T) {
+	if _, err := Source([]byte("let = 5;")); err == nil {
+		t.Errorf("expected an error for source code that does not parse")
+	}
+}
@@ -22,46 +22,112 @@
 // 0 = NUMBER
 // ; = SEMI_COLON
 // etc...
+//
+// The source code is consumed one byte at a time from a buffered reader,
+// such that large or piped input can be tokenized without holding all of it
+// in memory.
 package lexer
 
 import (
+	"bufio"
+	"fmt"
+	"io"
 	"monkey/token"
+	"slices"
+	"strings"
 )
 
 type Lexer struct {
-	input string
+	input *bufio.Reader
+	err   error // the first error returned by the reader, other than io.EOF
 
 	position     int  // current index position in the source code
 	readPosition int  // position + 1
 	currentChar  byte // current char
+
+	line   int // line of the current char, starting at 1
+	column int // column of the current char, starting at 1
+
+	operators []string // registered operators, longest first
 }
 
 func New(input string) *Lexer {
-	lexer := &Lexer{input: input}
+	return NewReader(strings.NewReader(input))
+}
+
+// Creates a lexer that reads the source code from r in buffered chunks
+// A read error ends the token stream as if the input ended, see Err
+func NewReader(r io.Reader) *Lexer {
+	lexer := &Lexer{input: bufio.NewReader(r), line: 1}
 	lexer.readChar()
 
 	return lexer
 }
 
+// Returns the error that stopped reading the input, if any
+func (l *Lexer) Err() error {
+	return l.err
+}
+
+// Makes the lexer produce a token for the operator, e.g. `|>`, with the
+// operator itself as the token type. Registered operators take precedence
+// over the builtin ones, the longest operator that matches wins.
+// Must be called before the lexer is used, typically before creating the
+// parser, see parser.Parser.RegisterInfixOperator.
+func (l *Lexer) RegisterOperator(operator string) {
+	if operator == "" || isLetter(operator[0]) || isNumber(operator[0]) || strings.ContainsAny(operator, " \t\n") {
+		panic(fmt.Sprintf("lexer: invalid operator %q", operator))
+	}
+
+	i := 0
+	for i < len(l.operators) && len(l.operators[i]) >= len(operator) {
+		i++
+	}
+
+	l.operators = slices.Insert(l.operators, i, operator)
+}
+
 func (l *Lexer) readChar() {
+	if l.currentChar == '\n' {
+		l.line += 1
+		l.column = 0
+	}
+
+	l.column += 1
 	l.currentChar = l.peekChar()
 	l.position = l.readPosition
 	l.readPosition += 1
+
+	if l.currentChar != 0 {
+		l.input.ReadByte()
+	}
 }
 
 func (l *Lexer) peekChar() byte {
-	if l.readPosition >= len(l.input) {
+	next, err := l.input.Peek(1)
+	if err != nil {
+		if err != io.EOF && l.err == nil {
+			l.err = err
+		}
 		return 0
 	}
 
-	return l.input[l.readPosition]
+	return next[0]
 }
 
 func (l *Lexer) NextToken() token.Token {
 	l.eatWhiteSpace()
 
 	t := token.Token{
-		Literal: string(l.currentChar),
+		// a byte slice, string(byte) would encode bytes >= 0x80 as a rune
+		Literal: string([]byte{l.currentChar}),
+		Pos:     l.currentPosition(),
+	}
+
+	if operator := l.readOperator(); operator != "" {
+		t.Type = token.TokenType(operator)
+		t.Literal = operator
+		return t
 	}
 
 	switch l.currentChar {
@@ -78,9 +144,23 @@ func (l *Lexer) NextToken() token.Token {
 	case '-':
 		t.Type = token.MINUS
 	case '/':
-		t.Type = token.SLASH
+		if l.peekChar() == '/' {
+			t.Type = token.COMMENT
+			t.Literal = l.readComment()
+			return t
+		} else {
+			t.Type = token.SLASH
+		}
 	case '*':
-		t.Type = token.ASTERISK
+		if l.peekChar() == '*' {
+			t.Type = token.POWER
+			t.Literal = string(l.currentChar) + string(l.peekChar())
+			l.readChar()
+		} else {
+			t.Type = token.ASTERISK
+		}
+	case '?':
+		t.Type = token.QUESTION
 	case '!':
 		if l.peekChar() == '=' {
 			t.Type = token.NOT_EQ
@@ -93,6 +173,18 @@ func (l *Lexer) NextToken() token.Token {
 		t.Type = token.COMMA
 	case ';':
 		t.Type = token.SEMICOLON
+	case ':':
+		t.Type = token.COLON
+	case '.':
+		t.Type = token.DOT
+	case '|':
+		if l.peekChar() == '>' {
+			t.Type = token.PIPE
+			t.Literal = string(l.currentChar) + string(l.peekChar())
+			l.readChar()
+		} else {
+			t.Type = token.ILLEGAL
+		}
 	case '(':
 		t.Type = token.LPAREN
 	case ')':
@@ -126,6 +218,15 @@ func (l *Lexer) NextToken() token.Token {
 	return t
 }
 
+// The position of the current char in the source code
+func (l *Lexer) currentPosition() token.Position {
+	return token.Position{
+		Offset: l.position,
+		Line:   l.line,
+		Column: l.column,
+	}
+}
+
 func isLetter(ch byte) bool {
 	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || ch == '_'
 }
@@ -138,17 +239,33 @@ func isWhitespace(ch byte) bool {
 	return ch == ' ' || ch == '\t' || ch == '\n'
 }
 
-func getIdentifier(identifier string) token.TokenType {
-	keywords := map[string]token.TokenType{
-		"let":    token.LET,
-		"fn":     token.FUNCTION,
-		"true":   token.TRUE,
-		"false":  token.FALSE,
-		"return": token.RETURN,
-		"if":     token.IF,
-		"else":   token.ELSE,
+var keywords = map[string]token.TokenType{
+	"let":    token.LET,
+	"fn":     token.FUNCTION,
+	"true":   token.TRUE,
+	"false":  token.FALSE,
+	"return": token.RETURN,
+	"if":     token.IF,
+	"else":   token.ELSE,
+}
+
+// Reports whether name lexes as a single identifier, i.e. it consists of
+// letters and underscores only and is not a keyword
+func IsIdentifier(name string) bool {
+	if name == "" {
+		return false
 	}
 
+	for i := 0; i < len(name); i++ {
+		if !isLetter(name[i]) {
+			return false
+		}
+	}
+
+	return getIdentifier(name) == token.IDENT
+}
+
+func getIdentifier(identifier string) token.TokenType {
 	tokenType, ok := keywords[identifier]
 	if ok {
 		return tokenType
@@ -158,23 +275,60 @@ func getIdentifier(identifier string) token.TokenType {
 }
 
 func (l *Lexer) readIdentifier() string {
-	left := l.position
+	var out strings.Builder
 
 	for isLetter(l.currentChar) {
+		out.WriteByte(l.currentChar)
 		l.readChar()
 	}
 
-	return l.input[left:l.position]
+	return out.String()
 }
 
 func (l *Lexer) readNumber() string {
-	left := l.position
+	var out strings.Builder
 
 	for isNumber(l.currentChar) {
+		out.WriteByte(l.currentChar)
+		l.readChar()
+	}
+
+	return out.String()
+}
+
+// Reads the registered operator that starts at the current char, if any
+func (l *Lexer) readOperator() string {
+	for _, operator := range l.operators {
+		if operator[0] != l.currentChar {
+			continue
+		}
+
+		// the current char is already consumed from the input
+		rest, _ := l.input.Peek(len(operator) - 1)
+		if string(rest) != operator[1:] {
+			continue
+		}
+
+		for range operator {
+			l.readChar()
+		}
+
+		return operator
+	}
+
+	return ""
+}
+
+// Reads a comment up to, but not including, the end of the line
+func (l *Lexer) readComment() string {
+	var out strings.Builder
+
+	for l.currentChar != '\n' && l.currentChar != 0 {
+		out.WriteByte(l.currentChar)
 		l.readChar()
 	}
 
-	return l.input[left:l.position]
+	return out.String()
 }
 
 func (l *Lexer) eatWhiteSpace() {
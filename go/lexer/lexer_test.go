@@ -1,8 +1,12 @@
 package lexer
 
 import (
+	"errors"
+	"io"
 	"monkey/token"
+	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 func TestNextToken(t *testing.T) {
@@ -37,6 +41,45 @@ func TestNextToken(t *testing.T) {
 	}
 }
 
+func TestOperators(t *testing.T) {
+	input := `a.b |> c ** d * e ? f : g |`
+
+	tests := []struct {
+		Type    token.TokenType
+		Literal string
+	}{
+		{token.IDENT, "a"},
+		{token.DOT, "."},
+		{token.IDENT, "b"},
+		{token.PIPE, "|>"},
+		{token.IDENT, "c"},
+		{token.POWER, "**"},
+		{token.IDENT, "d"},
+		{token.ASTERISK, "*"},
+		{token.IDENT, "e"},
+		{token.QUESTION, "?"},
+		{token.IDENT, "f"},
+		{token.COLON, ":"},
+		{token.IDENT, "g"},
+		{token.ILLEGAL, "|"},
+		{token.EOF, ""},
+	}
+
+	lexer := New(input)
+
+	for i, expected := range tests {
+		actual := lexer.NextToken()
+
+		if actual.Type != expected.Type {
+			t.Fatalf("tests[%d] - incorrect token type: expected=%q, got=%q", i, expected.Type, actual.Type)
+		}
+
+		if actual.Literal != expected.Literal {
+			t.Fatalf("tests[%d] - incorrect token literal: expected=%q, got=%q", i, expected.Literal, actual.Literal)
+		}
+	}
+}
+
 func TestMoreSourceCode(t *testing.T) {
 	input := `let myIdentifier = 5;`
 
@@ -224,3 +267,306 @@ if (5 < 10) {
 		}
 	}
 }
+
+func TestTokenPositions(t *testing.T) {
+	input := `let x = 5;
+  x != 10;
+`
+
+	tests := []struct {
+		Type     token.TokenType
+		Position token.Position
+	}{
+		{token.LET, token.Position{Offset: 0, Line: 1, Column: 1}},
+		{token.IDENT, token.Position{Offset: 4, Line: 1, Column: 5}},
+		{token.ASSIGN, token.Position{Offset: 6, Line: 1, Column: 7}},
+		{token.INT, token.Position{Offset: 8, Line: 1, Column: 9}},
+		{token.SEMICOLON, token.Position{Offset: 9, Line: 1, Column: 10}},
+		{token.IDENT, token.Position{Offset: 13, Line: 2, Column: 3}},
+		{token.NOT_EQ, token.Position{Offset: 15, Line: 2, Column: 5}},
+		{token.INT, token.Position{Offset: 18, Line: 2, Column: 8}},
+		{token.SEMICOLON, token.Position{Offset: 20, Line: 2, Column: 10}},
+		{token.EOF, token.Position{Offset: 22, Line: 3, Column: 1}},
+	}
+
+	lexer := New(input)
+
+	for i, expected := range tests {
+		actual := lexer.NextToken()
+
+		if actual.Type != expected.Type {
+			t.Fatalf("tests[%d] - incorrect token type: expected=%q, got=%q", i, expected.Type, actual.Type)
+		}
+
+		if actual.Pos != expected.Position {
+			t.Fatalf("tests[%d] - incorrect token position: expected=%+v, got=%+v", i, expected.Position, actual.Pos)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// leading comment
+let x = 10 / 2; // trailing comment
+//`
+
+	tests := []struct {
+		Type    token.TokenType
+		Literal string
+	}{
+		{token.COMMENT, "// leading comment"},
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "10"},
+		{token.SLASH, "/"},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.COMMENT, "// trailing comment"},
+		{token.COMMENT, "//"},
+		{token.EOF, ""},
+	}
+
+	lexer := New(input)
+
+	for i, expected := range tests {
+		actual := lexer.NextToken()
+
+		if actual.Type != expected.Type {
+			t.Fatalf("tests[%d] - incorrect token type: expected=%q, got=%q", i, expected.Type, actual.Type)
+		}
+
+		if actual.Literal != expected.Literal {
+			t.Fatalf("tests[%d] - incorrect token literal: expected=%q, got=%q", i, expected.Literal, actual.Literal)
+		}
+	}
+}
+
+func TestRegisterOperator(t *testing.T) {
+	input := "x |> f ** 2 * 3 |"
+
+	tests := []struct {
+		Type    token.TokenType
+		Literal string
+		Column  int
+	}{
+		{token.IDENT, "x", 1},
+		{"|>", "|>", 3},
+		{token.IDENT, "f", 6},
+		{"**", "**", 8},
+		{token.INT, "2", 11},
+		{token.ASTERISK, "*", 13},
+		{token.INT, "3", 15},
+		{token.ILLEGAL, "|", 17},
+		{token.EOF, "", 18},
+	}
+
+	lexer := New(input)
+	lexer.RegisterOperator("*")
+	lexer.RegisterOperator("|>")
+	lexer.RegisterOperator("**")
+
+	for i, expected := range tests {
+		actual := lexer.NextToken()
+
+		if actual.Type != expected.Type {
+			t.Fatalf("tests[%d] - incorrect token type: expected=%q, got=%q", i, expected.Type, actual.Type)
+		}
+
+		if actual.Literal != expected.Literal {
+			t.Fatalf("tests[%d] - incorrect token literal: expected=%q, got=%q", i, expected.Literal, actual.Literal)
+		}
+
+		if actual.Pos.Column != expected.Column {
+			t.Fatalf("tests[%d] - incorrect column: expected=%d, got=%d", i, expected.Column, actual.Pos.Column)
+		}
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	// larger than the default buffer size of the reader, such that tokens
+	// and lines span chunk boundaries
+	var source strings.Builder
+	for i := 0; source.Len() < 3*4096; i++ {
+		source.WriteString("let someLongerIdentifier = 1234567 * 89; // a comment\n")
+		source.WriteString("\tsomeLongerIdentifier != 42;\n")
+	}
+
+	readers := map[string]func() *Lexer{
+		"whole":    func() *Lexer { return NewReader(strings.NewReader(source.String())) },
+		"one byte": func() *Lexer { return NewReader(iotest.OneByteReader(strings.NewReader(source.String()))) },
+		"half":     func() *Lexer { return NewReader(iotest.HalfReader(strings.NewReader(source.String()))) },
+	}
+
+	for name, newLexer := range readers {
+		expected := New(source.String())
+		actual := newLexer()
+
+		for i := 0; ; i++ {
+			want, got := expected.NextToken(), actual.NextToken()
+
+			if got != want {
+				t.Fatalf("%s: token[%d] - expected=%+v, got=%+v", name, i, want, got)
+			}
+
+			if want.Type == token.EOF {
+				break
+			}
+		}
+
+		if actual.Err() != nil {
+			t.Errorf("%s: unexpected error: %v", name, actual.Err())
+		}
+	}
+}
+
+func TestNewReaderError(t *testing.T) {
+	readErr := errors.New("broken pipe")
+	lexer := NewReader(io.MultiReader(strings.NewReader("let x"), iotest.ErrReader(readErr)))
+
+	for _, expected := range []token.TokenType{token.LET, token.IDENT, token.EOF} {
+		if tok := lexer.NextToken(); tok.Type != expected {
+			t.Fatalf("expected=%q, got=%+v", expected, tok)
+		}
+	}
+
+	if !errors.Is(lexer.Err(), readErr) {
+		t.Errorf("expected the read error to be reported, got=%v", lexer.Err())
+	}
+}
+
+func TestTokenStream(t *testing.T) {
+	stream := NewTokenStream(New("let x = 5;"))
+
+	if tok := stream.Peek(3); tok.Literal != "5" {
+		t.Fatalf("Peek(3): expected=%q, got=%q", "5", tok.Literal)
+	}
+
+	if tok := stream.Next(); tok.Type != token.LET {
+		t.Fatalf("Next: expected=%q, got=%q", token.LET, tok.Type)
+	}
+
+	mark := stream.Mark()
+	stream.Next()
+	stream.Next()
+
+	if tok := stream.Peek(0); tok.Type != token.INT {
+		t.Fatalf("Peek(0): expected=%q, got=%q", token.INT, tok.Type)
+	}
+
+	stream.Reset(mark)
+
+	var literals []string
+	for tok := range stream.All() {
+		literals = append(literals, tok.Literal)
+	}
+
+	if strings.Join(literals, " ") != "x = 5 ;" {
+		t.Errorf("All after Reset: got=%q", literals)
+	}
+
+	// the stream keeps returning EOF once it is exhausted
+	for i := 0; i < 3; i++ {
+		if tok := stream.Next(); tok.Type != token.EOF {
+			t.Fatalf("expected EOF, got=%+v", tok)
+		}
+	}
+	if tok := stream.Peek(10); tok.Type != token.EOF {
+		t.Fatalf("expected EOF when peeking past the end, got=%+v", tok)
+	}
+}
+
+// Produces count integers, followed by EOF
+type countingSource struct {
+	count int
+}
+
+func (c *countingSource) NextToken() token.Token {
+	if c.count == 0 {
+		return token.Token{Type: token.EOF}
+	}
+
+	c.count--
+	return token.Token{Type: token.INT, Literal: "1"}
+}
+
+func TestTokenStreamDropsConsumedTokens(t *testing.T) {
+	tokens := NewTokenStream(&countingSource{count: 10000})
+
+	consumed := 0
+	for range tokens.All() {
+		tokens.Peek(2)
+		if buffered := len(tokens.(*stream).tokens); buffered > 3 {
+			t.Fatalf("expected only the lookahead to be held, %d tokens are buffered", buffered)
+		}
+		consumed++
+	}
+
+	if consumed != 10000 {
+		t.Errorf("expected 10000 tokens, got=%d", consumed)
+	}
+}
+
+func TestTokenStreamKeepsMarkedTokens(t *testing.T) {
+	tokens := NewTokenStream(&countingSource{count: 100})
+	buffered := func() int { return len(tokens.(*stream).tokens) }
+
+	outer := tokens.Mark()
+	for range 10 {
+		tokens.Next()
+	}
+	inner := tokens.Mark()
+	for range 10 {
+		tokens.Next()
+	}
+
+	tokens.Reset(inner)
+	if buffered() != 20 {
+		t.Fatalf("expected the tokens since the outer mark to be held, %d tokens are buffered", buffered())
+	}
+
+	tokens.Reset(outer)
+	for range 5 {
+		tokens.Next()
+	}
+	if buffered() != 15 {
+		t.Fatalf("expected the tokens left after the marks are given up, %d tokens are buffered", buffered())
+	}
+
+	mark := tokens.Mark()
+	tokens.Next()
+	tokens.Release(mark)
+	if buffered() != 14 {
+		t.Fatalf("expected a released mark to drop the consumed tokens, %d tokens are buffered", buffered())
+	}
+
+	consumed := 6
+	for range tokens.All() {
+		consumed++
+	}
+	if consumed != 100 {
+		t.Errorf("expected 100 tokens, got=%d", consumed)
+	}
+}
+
+func TestFromTokens(t *testing.T) {
+	stream := FromTokens([]token.Token{
+		{Type: token.IDENT, Literal: "a"},
+		{Type: token.PLUS, Literal: "+"},
+	})
+
+	for i, expected := range []token.TokenType{token.IDENT, token.PLUS, token.EOF, token.EOF} {
+		if tok := stream.Next(); tok.Type != expected {
+			t.Fatalf("tests[%d] - expected=%q, got=%q", i, expected, tok.Type)
+		}
+	}
+
+	// breaking out of the loop stops consuming
+	stream = FromTokens([]token.Token{{Type: token.INT, Literal: "1"}, {Type: token.INT, Literal: "2"}})
+	for range stream.All() {
+		break
+	}
+
+	if tok := stream.Next(); tok.Literal != "2" {
+		t.Errorf("expected the second token after breaking out of All, got=%+v", tok)
+	}
+}
@@ -0,0 +1,143 @@
+package lexer
+
+import (
+	"iter"
+	"monkey/token"
+)
+
+// A stream of tokens with arbitrary lookahead and backtracking
+//
+// Parsers consume tokens through this interface, such that they can be
+// driven by the lexer or by any other source of tokens, e.g. a slice of
+// tokens in tests. Once the tokens run out the stream keeps returning EOF.
+type TokenStream interface {
+	// Consumes and returns the next token
+	Next() token.Token
+
+	// Returns the token n positions ahead without consuming anything,
+	// Peek(0) is the token that the next call to Next returns
+	Peek(n int) token.Token
+
+	// Returns a mark of the current position in the stream. The tokens
+	// consumed after the mark are retained until the mark is given up
+	// through Reset or Release.
+	Mark() int
+
+	// Rewinds the stream to a mark and gives it up, such that the tokens
+	// consumed since the mark are returned again
+	Reset(mark int)
+
+	// Gives up a mark without rewinding the stream
+	Release(mark int)
+
+	// Iterates over the remaining tokens up to, but not including, EOF,
+	// consuming them
+	All() iter.Seq[token.Token]
+}
+
+// Produces tokens one at a time, *Lexer is a TokenSource
+type TokenSource interface {
+	NextToken() token.Token
+}
+
+// Creates a token stream over the tokens produced by the source
+// Tokens are read from the source when they are first peeked or consumed,
+// and dropped once consumed while no mark is outstanding, such that only the
+// lookahead and the tokens since the oldest mark are held in memory.
+func NewTokenStream(source TokenSource) TokenStream {
+	return &stream{source: source}
+}
+
+// Creates a token stream over a fixed list of tokens
+// An EOF token is added when the list does not end with one.
+func FromTokens(tokens []token.Token) TokenStream {
+	s := &stream{tokens: append([]token.Token(nil), tokens...)}
+
+	if len(s.tokens) == 0 || s.tokens[len(s.tokens)-1].Type != token.EOF {
+		s.tokens = append(s.tokens, token.Token{Type: token.EOF})
+	}
+
+	return s
+}
+
+type stream struct {
+	source TokenSource // nil when all tokens are known up front
+
+	// the tokens read from the source that are still held, tokens[0] is the
+	// token at position offset, counted from the start of the stream
+	tokens []token.Token
+	offset int
+
+	position int // of the next token to consume
+	marks    int // outstanding marks, tokens are only dropped when zero
+}
+
+// Makes sure the tokens up to and including index are read, unless EOF is
+// reached first
+func (s *stream) fill(index int) {
+	index -= s.offset
+	for len(s.tokens) <= index && s.source != nil {
+		if n := len(s.tokens); n > 0 && s.tokens[n-1].Type == token.EOF {
+			return
+		}
+
+		s.tokens = append(s.tokens, s.source.NextToken())
+	}
+}
+
+func (s *stream) Peek(n int) token.Token {
+	index := s.position + max(n, 0)
+	s.fill(index)
+
+	if index-s.offset >= len(s.tokens) {
+		// past the end, the last token is EOF
+		return s.tokens[len(s.tokens)-1]
+	}
+
+	return s.tokens[index-s.offset]
+}
+
+func (s *stream) Next() token.Token {
+	tok := s.Peek(0)
+
+	// the final EOF stays, it is returned from then on
+	if tok.Type != token.EOF {
+		s.position++
+		s.drop()
+	}
+
+	return tok
+}
+
+func (s *stream) Mark() int {
+	s.marks++
+	return s.position
+}
+
+func (s *stream) Reset(mark int) {
+	s.position = min(max(mark, s.offset), s.offset+len(s.tokens))
+	s.Release(mark)
+}
+
+func (s *stream) Release(mark int) {
+	s.marks = max(s.marks-1, 0)
+	s.drop()
+}
+
+// Drops the consumed tokens unless a mark may still return to them
+func (s *stream) drop() {
+	if s.marks == 0 {
+		s.tokens = s.tokens[s.position-s.offset:]
+		s.offset = s.position
+	}
+}
+
+func (s *stream) All() iter.Seq[token.Token] {
+	return func(yield func(token.Token) bool) {
+		for tok := s.Next(); tok.Type != token.EOF; tok = s.Next() {
+			if !yield(tok) {
+				return
+			}
+		}
+	}
+}
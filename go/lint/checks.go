@@ -0,0 +1,105 @@
+package lint
+
+import (
+	"monkey/ast"
+	"monkey/types"
+)
+
+var unusedVariableCheck = &Check{
+	Code: "unused-variable",
+	Doc:  "let bindings that are never referenced",
+	run: func(pass *pass) {
+		for _, b := range pass.scope.Bindings {
+			if len(b.References) == 0 && b.Name.Identifier != "_" {
+				pass.report(b.Name.Pos(), "%s declared and not used", b.Name.Identifier)
+			}
+		}
+	},
+}
+
+var shadowedVariableCheck = &Check{
+	Code: "shadowed-variable",
+	Doc:  "let bindings that hide an earlier binding with the same name",
+	run: func(pass *pass) {
+		for _, b := range pass.scope.Bindings {
+			if b.Shadows != nil {
+				pass.report(b.Name.Pos(), "%s shadows the declaration at %s",
+					b.Name.Identifier, b.Shadows.Name.Pos())
+			}
+		}
+	},
+}
+
+var undefinedIdentifierCheck = &Check{
+	Code: "undefined-identifier",
+	Doc:  "identifiers that are used without being declared",
+	run: func(pass *pass) {
+		for _, identifier := range pass.scope.Undefined {
+			pass.report(identifier.Pos(), "undefined: %s", identifier.Identifier)
+		}
+	},
+}
+
+var unreachableCodeCheck = &Check{
+	Code: "unreachable-code",
+	Doc:  "statements that follow a return statement",
+	run: func(pass *pass) {
+		for i, statement := range pass.program.Statements {
+			if _, ok := statement.(*ast.ReturnStatement); ok && i+1 < len(pass.program.Statements) {
+				// reporting the first unreachable statement is enough
+				pass.report(pass.program.Statements[i+1].Pos(), "unreachable code")
+				return
+			}
+		}
+	},
+}
+
+var selfComparisonCheck = &Check{
+	Code: "self-comparison",
+	Doc:  "comparisons of an expression with itself, which are always true or false",
+	run: func(pass *pass) {
+		ast.Inspect(pass.program, func(node ast.Node) bool {
+			infix, ok := node.(*ast.InfixExpression)
+			if !ok || infix.Left == nil || infix.Right == nil {
+				return true
+			}
+
+			switch infix.Operator {
+			case "==", "!=", "<", ">":
+				// apart from calls, expressions have no side effects, so
+				// the same source text always produces the same value
+				if infix.Left.String() == infix.Right.String() && !containsCall(infix.Left) {
+					pass.report(infix.Pos(), "comparison of %s with itself", infix.Left)
+				}
+			}
+
+			return true
+		})
+	},
+}
+
+// Reports whether the expression calls a function, which may return a
+// different value each time, e.g. `rand()`
+func containsCall(expression ast.Expression) bool {
+	found := false
+
+	ast.Inspect(expression, func(node ast.Node) bool {
+		switch node.(type) {
+		case *ast.CallExpression, *ast.MethodCallExpression, *ast.PipeExpression:
+			found = true
+		}
+		return !found
+	})
+
+	return found
+}
+
+var typeErrorCheck = &Check{
+	Code: "type-error",
+	Doc:  "operations and annotations whose types do not match",
+	run: func(pass *pass) {
+		for _, err := range types.Check(pass.program).Errors {
+			pass.report(err.Pos, "%s", err.Message)
+		}
+	},
+}
@@ -0,0 +1,93 @@
+// Package lint implements static checks that find likely bugs in monkey
+// programs without running them.
+//
+// Each check reports its findings as diagnostics that carry a stable code,
+// such that individual checks can be enabled or disabled by their code,
+// e.g. `unused-variable`.
+package lint
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/scope"
+	"monkey/token"
+	"sort"
+)
+
+// A finding of one of the checks
+type Diagnostic struct {
+	Pos     token.Position
+	Code    string // the code of the check that reported it
+	Message string
+}
+
+// Formats the diagnostic as `line:column: message (code)`
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s (%s)", d.Pos, d.Message, d.Code)
+}
+
+// A single static check
+type Check struct {
+	Code string // stable identifier, used to enable or disable the check
+	Doc  string // one line description of what the check reports
+
+	run func(pass *pass)
+}
+
+// All available checks, in the order in which they are documented
+var Checks = []*Check{
+	unusedVariableCheck,
+	shadowedVariableCheck,
+	undefinedIdentifierCheck,
+	unreachableCodeCheck,
+	selfComparisonCheck,
+	typeErrorCheck,
+}
+
+// Returns the check with the given code, or nil when there is none
+func Lookup(code string) *Check {
+	for _, check := range Checks {
+		if check.Code == code {
+			return check
+		}
+	}
+
+	return nil
+}
+
+// Runs the checks over the program, returns their diagnostics ordered by
+// position
+func Lint(program *ast.Program, checks []*Check) []Diagnostic {
+	pass := &pass{
+		program: program,
+		scope:   scope.Resolve(program),
+	}
+
+	for _, check := range checks {
+		pass.check = check
+		check.run(pass)
+	}
+
+	sort.SliceStable(pass.diagnostics, func(i, j int) bool {
+		return pass.diagnostics[i].Pos.Offset < pass.diagnostics[j].Pos.Offset
+	})
+
+	return pass.diagnostics
+}
+
+// The state shared by the checks while linting one program
+type pass struct {
+	program *ast.Program
+	scope   *scope.Info
+
+	check       *Check // the check that is currently running
+	diagnostics []Diagnostic
+}
+
+func (p *pass) report(pos token.Position, format string, args ...any) {
+	p.diagnostics = append(p.diagnostics, Diagnostic{
+		Pos:     pos,
+		Code:    p.check.Code,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
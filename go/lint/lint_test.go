@@ -0,0 +1,103 @@
+package lint
+
+import (
+	"monkey/lexer"
+	"monkey/parser"
+	"reflect"
+	"testing"
+)
+
+func TestChecks(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			"let x = 5; x;",
+			nil,
+		},
+		{
+			"let x = 5;",
+			[]string{"1:5: x declared and not used (unused-variable)"},
+		},
+		{
+			"let _ = 5;",
+			nil,
+		},
+		{
+			"let x = 1;\nlet x = x + 1;\nx;",
+			[]string{"2:5: x shadows the declaration at 1:5 (shadowed-variable)"},
+		},
+		{
+			"let x = 1;\nlet x = 2;\nx;",
+			[]string{
+				"1:5: x declared and not used (unused-variable)",
+				"2:5: x shadows the declaration at 1:5 (shadowed-variable)",
+			},
+		},
+		{
+			"let y = x; y;",
+			[]string{"1:9: undefined: x (undefined-identifier)"},
+		},
+		{
+			// the value is evaluated before the name is bound
+			"let x = x;",
+			[]string{
+				"1:5: x declared and not used (unused-variable)",
+				"1:9: undefined: x (undefined-identifier)",
+			},
+		},
+		{
+			"return 1;\nlet x = 2;\nx;",
+			[]string{"2:1: unreachable code (unreachable-code)"},
+		},
+		{
+			"let a = 1;\na == a;\na + 1 != a + 1;\na < 1;",
+			[]string{
+				"2:1: comparison of a with itself (self-comparison)",
+				"3:1: comparison of (a + 1) with itself (self-comparison)",
+			},
+		},
+		{
+			// calls may return a different value each time
+			"let rand = 1;\nrand() == rand();\nrand.rand() != rand.rand();\nrand |> rand < rand |> rand;",
+			nil,
+		},
+		{
+			"let a: bool = 1;\na;",
+			[]string{"1:15: cannot use 1 (int) as bool (type-error)"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		p := parser.New(lexer.New(testCase.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			t.Fatalf("input %q: parser errors: %v", testCase.input, p.Errors())
+		}
+
+		var actual []string
+		for _, diagnostic := range Lint(program, Checks) {
+			actual = append(actual, diagnostic.String())
+		}
+
+		if !reflect.DeepEqual(actual, testCase.expected) {
+			t.Errorf("input %q:\nexpected=%q\ngot=%q", testCase.input, testCase.expected, actual)
+		}
+	}
+}
+
+func TestDisabledChecks(t *testing.T) {
+	p := parser.New(lexer.New("let x = y;"))
+	program := p.ParseProgram()
+
+	diagnostics := Lint(program, []*Check{Lookup("undefined-identifier")})
+
+	if len(diagnostics) != 1 || diagnostics[0].Code != "undefined-identifier" {
+		t.Errorf("expected only the undefined-identifier check to run, got=%v", diagnostics)
+	}
+
+	if Lookup("no-such-check") != nil {
+		t.Errorf("expected Lookup of an unknown code to return nil")
+	}
+}
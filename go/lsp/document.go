@@ -0,0 +1,133 @@
+package lsp
+
+import (
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/scope"
+	"monkey/token"
+	"monkey/types"
+	"sort"
+	"unicode/utf8"
+)
+
+// An open text document and the result of analyzing its latest content
+type document struct {
+	uri  string
+	text string
+
+	lineStarts []int // byte offset at which each line starts
+
+	program *ast.Program
+	errors  []*parser.Error
+	scope   *scope.Info
+	types   *types.Info
+}
+
+func newDocument(uri, text string) *document {
+	doc := &document{uri: uri, text: text, lineStarts: []int{0}}
+
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			doc.lineStarts = append(doc.lineStarts, i+1)
+		}
+	}
+
+	p := parser.New(lexer.New(text))
+	doc.program = p.ParseProgram()
+	doc.errors = p.ErrorList()
+	doc.scope = scope.Resolve(doc.program)
+	doc.types = types.Check(doc.program)
+
+	return doc
+}
+
+// Converts a byte offset into an LSP position
+func (d *document) position(offset int) Position {
+	offset = min(max(offset, 0), len(d.text))
+
+	line := sort.Search(len(d.lineStarts), func(i int) bool {
+		return d.lineStarts[i] > offset
+	}) - 1
+
+	return Position{
+		Line:      line,
+		Character: utf16Length(d.text[d.lineStarts[line]:offset]),
+	}
+}
+
+// Converts an LSP position into a byte offset, positions past the end of a
+// line are clamped to the end of that line
+func (d *document) offset(pos Position) int {
+	if pos.Line < 0 {
+		return 0
+	}
+	if pos.Line >= len(d.lineStarts) {
+		return len(d.text)
+	}
+
+	offset := d.lineStarts[pos.Line]
+	for units := 0; units < pos.Character && offset < len(d.text); {
+		r, size := utf8.DecodeRuneInString(d.text[offset:])
+		if r == '\n' {
+			break
+		}
+
+		units += utf16RuneLength(r)
+		offset += size
+	}
+
+	return offset
+}
+
+// The range of text that starts at the token position and is length bytes
+// long
+func (d *document) span(pos token.Position, length int) Range {
+	return Range{
+		Start: d.position(pos.Offset),
+		End:   d.position(pos.Offset + length),
+	}
+}
+
+func (d *document) identifierRange(identifier *ast.Identifier) Range {
+	return d.span(identifier.Pos(), len(identifier.Identifier))
+}
+
+// The range covered by the tokens of the node
+func (d *document) nodeRange(node ast.Node) Range {
+	end := node.Pos().Offset
+
+	ast.Inspect(node, func(n ast.Node) bool {
+		if n != nil {
+			end = max(end, n.Pos().Offset+len(n.TokenLiteral()))
+		}
+		return true
+	})
+
+	return Range{
+		Start: d.position(node.Pos().Offset),
+		End:   d.position(end),
+	}
+}
+
+// The range of the whole document
+func (d *document) fullRange() Range {
+	return Range{Start: Position{}, End: d.position(len(d.text))}
+}
+
+func utf16Length(s string) int {
+	length := 0
+	for _, r := range s {
+		length += utf16RuneLength(r)
+	}
+
+	return length
+}
+
+func utf16RuneLength(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+
+	return 1
+}
@@ -0,0 +1,303 @@
+// Package lsp implements a language server for the monkey programming
+// language, speaking the Language Server Protocol over a pair of streams.
+//
+// The server keeps the documents that the editor opened in memory, and
+// re-parses a document on every change. It supports:
+// - diagnostics, from the parser and the linter
+// - go to definition and find references of let bindings
+// - hover, showing the declaration and the type of a binding
+// - document symbols, listing the let bindings
+// - formatting, using the canonical style of the format package
+package lsp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"monkey/ast"
+	"monkey/format"
+	"monkey/lint"
+	"monkey/scope"
+	"strings"
+)
+
+type server struct {
+	conn      *conn
+	documents map[string]*document // open documents by URI
+
+	shutdown bool // whether the client requested a shutdown
+}
+
+// Serves a single client until it sends the exit notification or closes
+// the input stream. Exiting without a prior shutdown request is an error.
+func Serve(in io.Reader, out io.Writer) error {
+	s := &server{
+		conn:      newConn(in, out),
+		documents: map[string]*document{},
+	}
+
+	for {
+		msg, err := s.conn.read()
+		if err == io.EOF {
+			return nil
+		}
+
+		var rpcErr *responseError
+		if errors.As(err, &rpcErr) {
+			// the message was framed correctly but is not valid JSON, the
+			// stream is still in sync so keep serving
+			if err := s.conn.reply(nil, nil, rpcErr); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("lsp: exit without shutdown")
+			}
+			return nil
+		}
+
+		if err := s.handle(msg); err != nil {
+			return err
+		}
+	}
+}
+
+// Dispatches a message to its handler. Only errors writing to the client
+// are returned, errors of the request itself are sent to the client.
+func (s *server) handle(msg *message) error {
+	if msg.ID == nil {
+		s.handleNotification(msg)
+		return nil
+	}
+
+	result, err := s.handleRequest(msg)
+	return s.conn.reply(msg.ID, result, err)
+}
+
+func (s *server) handleRequest(msg *message) (any, *responseError) {
+	if s.shutdown {
+		return nil, &responseError{Code: codeInvalidRequest, Message: "server is shutting down"}
+	}
+
+	switch msg.Method {
+	case "initialize":
+		return s.initialize(), nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/definition":
+		return withParams(msg, s.definition)
+	case "textDocument/references":
+		return withParams(msg, s.references)
+	case "textDocument/hover":
+		return withParams(msg, s.hover)
+	case "textDocument/documentSymbol":
+		return withParams(msg, s.documentSymbols)
+	case "textDocument/formatting":
+		return withParams(msg, s.formatting)
+	}
+
+	return nil, &responseError{Code: codeMethodNotFound, Message: "method not supported: " + msg.Method}
+}
+
+func (s *server) handleNotification(msg *message) {
+	// notifications cannot be answered, so invalid params are ignored
+	switch msg.Method {
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if json.Unmarshal(msg.Params, &params) == nil {
+			s.update(params.TextDocument.URI, params.TextDocument.Text)
+		}
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if json.Unmarshal(msg.Params, &params) == nil && len(params.ContentChanges) > 0 {
+			// with full synchronization the last change is the whole document
+			text := params.ContentChanges[len(params.ContentChanges)-1].Text
+			s.update(params.TextDocument.URI, text)
+		}
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if json.Unmarshal(msg.Params, &params) == nil {
+			delete(s.documents, params.TextDocument.URI)
+			s.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+				URI:         params.TextDocument.URI,
+				Diagnostics: []Diagnostic{},
+			})
+		}
+	}
+}
+
+// Decodes the params of the request and passes them to the handler
+func withParams[P any, R any](msg *message, handler func(P) (R, *responseError)) (any, *responseError) {
+	var params P
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return nil, &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+
+	return handler(params)
+}
+
+func (s *server) initialize() InitializeResult {
+	return InitializeResult{
+		Capabilities: ServerCapabilities{
+			TextDocumentSync:           textDocumentSyncFull,
+			DefinitionProvider:         true,
+			ReferencesProvider:         true,
+			HoverProvider:              true,
+			DocumentSymbolProvider:     true,
+			DocumentFormattingProvider: true,
+		},
+		ServerInfo: ServerInfo{Name: "monkey"},
+	}
+}
+
+// Analyzes the new content of a document and publishes its diagnostics
+func (s *server) update(uri, text string) {
+	doc := newDocument(uri, text)
+	s.documents[uri] = doc
+
+	diagnostics := []Diagnostic{}
+
+	for _, err := range doc.errors {
+		diagnostics = append(diagnostics, Diagnostic{
+			Range:    doc.span(err.Pos, 1),
+			Severity: severityError,
+			Source:   "monkey",
+			Message:  err.Message,
+		})
+	}
+
+	// lint results on a partially parsed program are mostly noise
+	if len(doc.errors) == 0 {
+		for _, d := range lint.Lint(doc.program, lint.Checks) {
+			diagnostics = append(diagnostics, Diagnostic{
+				Range:    doc.span(d.Pos, 1),
+				Severity: severityWarning,
+				Code:     d.Code,
+				Source:   "monkey vet",
+				Message:  d.Message,
+			})
+		}
+	}
+
+	s.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: diagnostics,
+	})
+}
+
+// Finds the document and the binding of the identifier at the position
+func (s *server) lookup(params TextDocumentPositionParams) (*document, *scope.Binding, *ast.Identifier, *responseError) {
+	doc, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return nil, nil, nil, &responseError{
+			Code:    codeInvalidParams,
+			Message: "document is not open: " + params.TextDocument.URI,
+		}
+	}
+
+	binding, identifier := doc.scope.BindingAt(doc.offset(params.Position))
+	return doc, binding, identifier, nil
+}
+
+func (s *server) definition(params TextDocumentPositionParams) (*Location, *responseError) {
+	doc, binding, _, err := s.lookup(params)
+	if err != nil || binding == nil {
+		return nil, err
+	}
+
+	return &Location{URI: doc.uri, Range: doc.identifierRange(binding.Name)}, nil
+}
+
+func (s *server) references(params ReferenceParams) ([]Location, *responseError) {
+	doc, binding, _, err := s.lookup(params.TextDocumentPositionParams)
+	if err != nil || binding == nil {
+		return nil, err
+	}
+
+	locations := []Location{}
+	if params.Context.IncludeDeclaration {
+		locations = append(locations, Location{URI: doc.uri, Range: doc.identifierRange(binding.Name)})
+	}
+
+	for _, reference := range binding.References {
+		locations = append(locations, Location{URI: doc.uri, Range: doc.identifierRange(reference)})
+	}
+
+	return locations, nil
+}
+
+func (s *server) hover(params TextDocumentPositionParams) (*Hover, *responseError) {
+	doc, binding, identifier, err := s.lookup(params)
+	if err != nil || binding == nil {
+		return nil, err
+	}
+
+	var declaration bytes.Buffer
+	format.Fprint(&declaration, &ast.Program{
+		Statements: []ast.Statement{
+			&ast.LetStatement{Name: binding.Name, Type: binding.Type, Value: binding.Value},
+		},
+	})
+
+	value := fmt.Sprintf("```monkey\n%s```\n\ntype: `%s`", declaration.String(), doc.types.Declarations[binding.Name])
+
+	return &Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: value},
+		Range:    doc.identifierRange(identifier),
+	}, nil
+}
+
+func (s *server) documentSymbols(params DocumentSymbolParams) ([]DocumentSymbol, *responseError) {
+	doc, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return nil, &responseError{Code: codeInvalidParams, Message: "document is not open: " + params.TextDocument.URI}
+	}
+
+	symbols := []DocumentSymbol{}
+	for _, statement := range doc.program.Statements {
+		let, ok := statement.(*ast.LetStatement)
+		if !ok || let.Name == nil {
+			continue
+		}
+
+		symbols = append(symbols, DocumentSymbol{
+			Name:           let.Name.Identifier,
+			Detail:         doc.types.Declarations[let.Name].String(),
+			Kind:           symbolKindVariable,
+			Range:          doc.nodeRange(let),
+			SelectionRange: doc.identifierRange(let.Name),
+		})
+	}
+
+	return symbols, nil
+}
+
+func (s *server) formatting(params DocumentFormattingParams) ([]TextEdit, *responseError) {
+	doc, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return nil, &responseError{Code: codeInvalidParams, Message: "document is not open: " + params.TextDocument.URI}
+	}
+
+	// formatting a program with syntax errors would drop code
+	if len(doc.errors) > 0 {
+		return nil, nil
+	}
+
+	var out strings.Builder
+	format.Fprint(&out, doc.program)
+
+	if out.String() == doc.text {
+		return []TextEdit{}, nil
+	}
+
+	return []TextEdit{{Range: doc.fullRange(), NewText: out.String()}}, nil
+}
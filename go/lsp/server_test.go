@@ -0,0 +1,317 @@
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// An in-process client, talking to a server over a pair of pipes
+type client struct {
+	t      *testing.T
+	conn   *conn
+	nextID int
+
+	done chan error // receives the result of Serve
+
+	// notifications received while waiting for a response
+	notifications []*message
+}
+
+func newClient(t *testing.T) *client {
+	clientIn, serverOut := io.Pipe()
+	serverIn, clientOut := io.Pipe()
+
+	c := &client{
+		t:    t,
+		conn: newConn(clientIn, clientOut),
+		done: make(chan error, 1),
+	}
+
+	go func() {
+		err := Serve(serverIn, serverOut)
+		serverOut.Close()
+		c.done <- err
+	}()
+
+	t.Cleanup(func() { clientOut.Close() })
+
+	return c
+}
+
+// Sends a request and decodes the result of its response into result
+func (c *client) call(method string, params any, result any) {
+	c.t.Helper()
+
+	c.nextID++
+	id := json.RawMessage(strings.TrimSpace(mustMarshal(c.t, c.nextID)))
+	if err := c.conn.write(&message{ID: &id, Method: method, Params: mustMarshalRaw(c.t, params)}); err != nil {
+		c.t.Fatalf("%s: writing request: %v", method, err)
+	}
+
+	for {
+		msg, err := c.conn.read()
+		if err != nil {
+			c.t.Fatalf("%s: reading response: %v", method, err)
+		}
+
+		if msg.ID == nil {
+			c.notifications = append(c.notifications, msg)
+			continue
+		}
+
+		if msg.Error != nil {
+			c.t.Fatalf("%s: unexpected error response: %v", method, msg.Error)
+		}
+
+		if result != nil {
+			if err := json.Unmarshal(msg.Result, result); err != nil {
+				c.t.Fatalf("%s: decoding result %s: %v", method, msg.Result, err)
+			}
+		}
+		return
+	}
+}
+
+func (c *client) notify(method string, params any) {
+	c.t.Helper()
+
+	if err := c.conn.notify(method, params); err != nil {
+		c.t.Fatalf("%s: writing notification: %v", method, err)
+	}
+}
+
+// Reads messages until the next notification with the given method
+func (c *client) waitFor(method string, params any) {
+	c.t.Helper()
+
+	for {
+		var msg *message
+		if len(c.notifications) > 0 {
+			msg, c.notifications = c.notifications[0], c.notifications[1:]
+		} else {
+			var err error
+			if msg, err = c.conn.read(); err != nil {
+				c.t.Fatalf("waiting for %s: %v", method, err)
+			}
+		}
+
+		if msg.Method == method {
+			if err := json.Unmarshal(msg.Params, params); err != nil {
+				c.t.Fatalf("%s: decoding params: %v", method, err)
+			}
+			return
+		}
+	}
+}
+
+func mustMarshal(t *testing.T, v any) string {
+	return string(mustMarshalRaw(t, v))
+}
+
+func mustMarshalRaw(t *testing.T, v any) json.RawMessage {
+	t.Helper()
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	return raw
+}
+
+const uri = "file:///test.mk"
+
+func openDocument(c *client, text string) PublishDiagnosticsParams {
+	c.notify("textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: uri, LanguageID: "monkey", Version: 1, Text: text},
+	})
+
+	var diagnostics PublishDiagnosticsParams
+	c.waitFor("textDocument/publishDiagnostics", &diagnostics)
+
+	return diagnostics
+}
+
+func at(line, character int) TextDocumentPositionParams {
+	return TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Position:     Position{Line: line, Character: character},
+	}
+}
+
+func TestLifecycle(t *testing.T) {
+	c := newClient(t)
+
+	var result InitializeResult
+	c.call("initialize", map[string]any{"capabilities": map[string]any{}}, &result)
+
+	if result.Capabilities.TextDocumentSync != textDocumentSyncFull || !result.Capabilities.HoverProvider {
+		t.Errorf("unexpected capabilities: %+v", result.Capabilities)
+	}
+
+	c.notify("initialized", map[string]any{})
+	c.call("shutdown", nil, nil)
+	c.notify("exit", nil)
+
+	if err := <-c.done; err != nil {
+		t.Errorf("expected a clean exit, got=%v", err)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	c := newClient(t)
+	c.call("initialize", map[string]any{}, nil)
+
+	diagnostics := openDocument(c, "let x = 5;\nlet = 10;")
+	if len(diagnostics.Diagnostics) == 0 {
+		t.Fatalf("expected a diagnostic for the syntax error")
+	}
+
+	d := diagnostics.Diagnostics[0]
+	if d.Severity != severityError || d.Range.Start != (Position{Line: 1, Character: 4}) {
+		t.Errorf("unexpected diagnostic: %+v", d)
+	}
+
+	// fixing the error replaces it with the warnings of the linter
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   TextDocumentIdentifier{URI: uri},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "let x = 5;\nlet y = 10;\ny;"}},
+	})
+	c.waitFor("textDocument/publishDiagnostics", &diagnostics)
+
+	if len(diagnostics.Diagnostics) != 1 || diagnostics.Diagnostics[0].Code != "unused-variable" {
+		t.Fatalf("expected an unused-variable warning, got=%+v", diagnostics.Diagnostics)
+	}
+
+	c.notify("textDocument/didClose", DidCloseTextDocumentParams{TextDocument: TextDocumentIdentifier{URI: uri}})
+	c.waitFor("textDocument/publishDiagnostics", &diagnostics)
+
+	if len(diagnostics.Diagnostics) != 0 {
+		t.Errorf("expected closing the document to clear its diagnostics, got=%+v", diagnostics.Diagnostics)
+	}
+}
+
+func TestNavigation(t *testing.T) {
+	c := newClient(t)
+	c.call("initialize", map[string]any{}, nil)
+
+	openDocument(c, "let x = 1;\nlet y = x + 2 > 1;\nlet x = x * 2;\nx + y;")
+
+	// the x on line 2 refers to the first binding
+	var definition Location
+	c.call("textDocument/definition", at(1, 8), &definition)
+	if definition.Range.Start != (Position{Line: 0, Character: 4}) {
+		t.Errorf("wrong definition: %+v", definition)
+	}
+
+	// the x on line 4 refers to the second binding
+	c.call("textDocument/definition", at(3, 0), &definition)
+	if definition.Range.Start != (Position{Line: 2, Character: 4}) {
+		t.Errorf("wrong definition: %+v", definition)
+	}
+
+	var references []Location
+	c.call("textDocument/references", ReferenceParams{
+		TextDocumentPositionParams: at(0, 4),
+		Context:                    ReferenceContext{IncludeDeclaration: true},
+	}, &references)
+
+	expected := []Position{{Line: 0, Character: 4}, {Line: 1, Character: 8}, {Line: 2, Character: 8}}
+	if len(references) != len(expected) {
+		t.Fatalf("expected %d references, got=%+v", len(expected), references)
+	}
+	for i, reference := range references {
+		if reference.Range.Start != expected[i] {
+			t.Errorf("reference[%d]: expected=%+v, got=%+v", i, expected[i], reference.Range.Start)
+		}
+	}
+
+	var hover Hover
+	c.call("textDocument/hover", at(3, 4), &hover)
+	if !strings.Contains(hover.Contents.Value, "let y = x + 2 > 1;") || !strings.Contains(hover.Contents.Value, "type: `bool`") {
+		t.Errorf("unexpected hover: %q", hover.Contents.Value)
+	}
+
+	var missing *Location
+	c.call("textDocument/definition", at(0, 0), &missing)
+	if missing != nil {
+		t.Errorf("expected no definition for a keyword, got=%+v", missing)
+	}
+}
+
+func TestHoverMissingValue(t *testing.T) {
+	c := newClient(t)
+	c.call("initialize", map[string]any{}, nil)
+
+	openDocument(c, "let x = ;\nx;")
+
+	var hover Hover
+	c.call("textDocument/hover", at(1, 0), &hover)
+	if !strings.Contains(hover.Contents.Value, "let x = ;") {
+		t.Errorf("unexpected hover: %q", hover.Contents.Value)
+	}
+}
+
+func TestParseErrorResponse(t *testing.T) {
+	c := newClient(t)
+
+	body := "{not json"
+	if _, err := io.WriteString(c.conn.out, fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(body), body)); err != nil {
+		t.Fatalf("writing request: %v", err)
+	}
+
+	header, err := c.conn.in.ReadString('\n')
+	if err != nil {
+		t.Fatalf("reading response: %v", err)
+	}
+	c.conn.in.ReadString('\n')
+
+	length, _ := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(header, "Content-Length:")))
+	response := make([]byte, length)
+	if _, err := io.ReadFull(c.conn.in, response); err != nil {
+		t.Fatalf("reading response: %v", err)
+	}
+
+	// JSON-RPC requires the id, null when it could not be read
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(response, &fields); err != nil {
+		t.Fatalf("decoding response %s: %v", response, err)
+	}
+	if id, ok := fields["id"]; !ok || string(id) != "null" {
+		t.Errorf("expected \"id\": null, got=%s", response)
+	}
+	if _, ok := fields["error"]; !ok {
+		t.Errorf("expected an error, got=%s", response)
+	}
+}
+
+func TestDocumentSymbolsAndFormatting(t *testing.T) {
+	c := newClient(t)
+	c.call("initialize", map[string]any{}, nil)
+
+	openDocument(c, "let a=1+2\nlet b = a>1 // compare\n")
+
+	var symbols []DocumentSymbol
+	c.call("textDocument/documentSymbol", DocumentSymbolParams{TextDocument: TextDocumentIdentifier{URI: uri}}, &symbols)
+
+	if len(symbols) != 2 || symbols[0].Name != "a" || symbols[1].Name != "b" {
+		t.Fatalf("unexpected symbols: %+v", symbols)
+	}
+	if symbols[0].Detail != "int" || symbols[1].Detail != "bool" {
+		t.Errorf("unexpected symbol types: %q, %q", symbols[0].Detail, symbols[1].Detail)
+	}
+	if symbols[0].Range.End != (Position{Line: 0, Character: 9}) {
+		t.Errorf("unexpected symbol range: %+v", symbols[0].Range)
+	}
+
+	var edits []TextEdit
+	c.call("textDocument/formatting", DocumentFormattingParams{TextDocument: TextDocumentIdentifier{URI: uri}}, &edits)
+
+	if len(edits) != 1 || edits[0].NewText != "let a = 1 + 2;\nlet b = a > 1; // compare\n" {
+		t.Errorf("unexpected formatting edits: %+v", edits)
+	}
+}
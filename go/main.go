@@ -1,20 +1,504 @@
+// Command monkey is the command-line driver for the monkey programming
+// language.
+//
+// Usage:
+//
+//	monkey repl             start an interactive session
+//	monkey tokens file.mk   print the tokens produced by the lexer, - is stdin
+//	monkey ast [-O] [-desugar] [-format text|json|sexpr|dot|html] file.mk
+//	                        print the AST produced by the parser
+//	monkey fmt [-l] [-w] file.mk...
+//	                        format source code in the canonical style
+//	monkey vet [-enable codes] [-disable codes] file.mk...
+//	                        report likely bugs found by static checks
+//	monkey lsp              run the language server over stdio
+//	monkey rename [-w] file.mk:line:col newName
+//	                        rename a let binding and its references
 package main
 
 import (
-	fmt "fmt"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"monkey/ast"
+	"monkey/astviz"
+	"monkey/desugar"
+	"monkey/format"
+	"monkey/lexer"
+	"monkey/lint"
+	"monkey/lsp"
+	"monkey/optimizer"
+	"monkey/parser"
+	"monkey/refactor"
 	"monkey/repl"
+	"monkey/token"
 	"os"
-	"os/user"
+	"strconv"
+	"strings"
 )
 
+const usage = `usage: monkey <command> [arguments]
+
+commands:
+  repl             start an interactive session
+  tokens file.mk   print the tokens produced by the lexer, - reads stdin
+  ast [-O] [-desugar] [-format text|json|sexpr|dot|html] file.mk
+                   print the AST produced by the parser, -O optimizes it,
+                   -desugar rewrites pipes and method calls into calls
+  fmt [-l] [-w] file.mk...
+                   format source code, -l lists unformatted files and
+                   fails if there are any, -w rewrites the files
+  vet [-enable codes] [-disable codes] file.mk...
+                   report likely bugs, see monkey vet -h for the checks
+  lsp              run the language server over stdin and stdout
+  rename [-w] file.mk:line:col newName
+                   rename the let binding at the position and all its
+                   references, -w rewrites the file
+`
+
+// Exit codes returned by the driver
+const (
+	exitOK    = 0
+	exitError = 1 // the command ran but failed, e.g. a parse error
+	exitUsage = 2 // the command line itself was invalid
+)
+
+// A command receives its (sub)command arguments and the streams to use
+type command func(args []string, stdin io.Reader, stdout, stderr io.Writer) int
+
+var commands = map[string]command{
+	"repl":   runRepl,
+	"tokens": runTokens,
+	"ast":    runAst,
+	"fmt":    runFmt,
+	"vet":    runVet,
+	"lsp":    runLsp,
+	"rename": runRename,
+}
+
 func main() {
-	user, err := user.Current()
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return exitOK
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "monkey: unknown command %q\n\n%s", args[0], usage)
+		return exitUsage
+	}
+
+	return cmd(args[1:], stdin, stdout, stderr)
+}
+
+func runRepl(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) != 0 {
+		fmt.Fprintf(stderr, "usage: monkey repl\n")
+		return exitUsage
+	}
+
+	fmt.Fprintln(stdout, "Hello! This is the monkey programming language REPL")
+	fmt.Fprintln(stdout, "Please enter commands")
+
+	repl.Start(stdin, stdout)
+	return exitOK
+}
+
+func runTokens(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) != 1 {
+		fmt.Fprintf(stderr, "usage: monkey tokens file.mk\n")
+		return exitUsage
+	}
+
+	// the file is streamed through the lexer instead of read up front,
+	// `-` reads from stdin
+	in := stdin
+	if args[0] != "-" {
+		file, err := os.Open(args[0])
+		if err != nil {
+			fmt.Fprintf(stderr, "monkey: %v\n", err)
+			return exitError
+		}
+		defer file.Close()
+
+		in = file
+	}
+
+	out := bufio.NewWriter(stdout)
+	defer out.Flush()
+
+	l := lexer.NewReader(in)
+	for tok := range lexer.NewTokenStream(l).All() {
+		fmt.Fprintf(out, "%+v\n", tok)
+	}
+
+	if err := l.Err(); err != nil {
+		fmt.Fprintf(stderr, "monkey: %v\n", err)
+		return exitError
+	}
+
+	return exitOK
+}
+
+func runAst(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := newFlagSet("ast", stderr)
+	optimize := flags.Bool("O", false, "fold constant expressions")
+	sugarFree := flags.Bool("desugar", false, "rewrite pipes and method calls into plain calls")
+	outputFormat := flags.String("format", "text", "output `format`: text, json, sexpr, dot or html")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	switch *outputFormat {
+	case "text", "json", "sexpr", "dot", "html":
+	default:
+		fmt.Fprintf(stderr, "monkey: unknown format %q\n", *outputFormat)
+		return exitUsage
+	}
+
+	source, code := readSourceArg("ast", flags.Args(), stderr)
+	if code != exitOK {
+		return code
+	}
+
+	program, ok := parseSource(source, stderr)
+	if !ok {
+		return exitError
+	}
+
+	if *sugarFree {
+		program = desugar.Desugar(program)
+	}
+
+	if *optimize {
+		program = optimizer.Fold(program)
+	}
+
+	switch *outputFormat {
+	case "json":
+		data, err := ast.MarshalJSON(program)
+		if err != nil {
+			fmt.Fprintf(stderr, "monkey: %v\n", err)
+			return exitError
+		}
+
+		var out bytes.Buffer
+		if err := json.Indent(&out, data, "", "  "); err != nil {
+			fmt.Fprintf(stderr, "monkey: %v\n", err)
+			return exitError
+		}
+		out.WriteByte('\n')
+
+		if _, err := stdout.Write(out.Bytes()); err != nil {
+			fmt.Fprintf(stderr, "monkey: %v\n", err)
+			return exitError
+		}
+	case "dot":
+		if err := astviz.Dot(stdout, program); err != nil {
+			fmt.Fprintf(stderr, "monkey: %v\n", err)
+			return exitError
+		}
+	case "html":
+		if err := astviz.HTML(stdout, program); err != nil {
+			fmt.Fprintf(stderr, "monkey: %v\n", err)
+			return exitError
+		}
+	case "sexpr":
+		for _, statement := range program.Statements {
+			fmt.Fprintln(stdout, ast.SExpr(statement))
+		}
+	default:
+		for _, statement := range program.Statements {
+			fmt.Fprintln(stdout, statement.String())
+		}
+	}
+
+	return exitOK
+}
+
+func runFmt(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := newFlagSet("fmt", stderr)
+	list := flags.Bool("l", false, "list files whose formatting differs, fail if there are any")
+	write := flags.Bool("w", false, "write the result to the file instead of stdout")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	if flags.NArg() == 0 {
+		fmt.Fprintf(stderr, "usage: monkey fmt [-l] [-w] file.mk...\n")
+		return exitUsage
+	}
+
+	code := exitOK
+	for _, path := range flags.Args() {
+		source, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(stderr, "monkey: %v\n", err)
+			code = exitError
+			continue
+		}
+
+		formatted, err := format.Source(source)
+		if err != nil {
+			fmt.Fprintf(stderr, "monkey: %s: %v\n", path, err)
+			code = exitError
+			continue
+		}
+
+		changed := !bytes.Equal(source, formatted)
+
+		if *list && changed {
+			fmt.Fprintln(stdout, path)
+			code = exitError
+		}
+
+		if *write && changed {
+			if err := os.WriteFile(path, formatted, 0o644); err != nil {
+				fmt.Fprintf(stderr, "monkey: %v\n", err)
+				code = exitError
+			}
+		}
+
+		if !*list && !*write {
+			stdout.Write(formatted)
+		}
+	}
+
+	return code
+}
+
+func runVet(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := newFlagSet("vet", stderr)
+	enable := flags.String("enable", "", "comma separated codes of the only checks to run")
+	disable := flags.String("disable", "", "comma separated codes of checks to skip")
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: monkey vet [-enable codes] [-disable codes] file.mk...\n\n")
+		flags.PrintDefaults()
+		fmt.Fprintf(stderr, "\nchecks:\n")
+		for _, check := range lint.Checks {
+			fmt.Fprintf(stderr, "  %-22s %s\n", check.Code, check.Doc)
+		}
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	checks, err := selectChecks(*enable, *disable)
 	if err != nil {
-		panic(err)
+		fmt.Fprintf(stderr, "monkey: %v\n", err)
+		return exitUsage
 	}
 
-	fmt.Printf("Hello %s! This is the monkey programming language REPL\n", user.Username)
-	fmt.Println("Please enter commands")
+	code := exitOK
+	for _, path := range flags.Args() {
+		source, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(stderr, "monkey: %v\n", err)
+			code = exitError
+			continue
+		}
+
+		program, ok := parseSource(string(source), stderr)
+		if !ok {
+			code = exitError
+			continue
+		}
+
+		for _, diagnostic := range lint.Lint(program, checks) {
+			fmt.Fprintf(stderr, "%s:%s\n", path, diagnostic)
+			code = exitError
+		}
+	}
+
+	return code
+}
+
+// Selects the checks to run from the comma separated -enable and -disable
+// lists, all checks are enabled by default
+func selectChecks(enable, disable string) ([]*lint.Check, error) {
+	lookup := func(list string) (map[*lint.Check]bool, error) {
+		checks := map[*lint.Check]bool{}
+		for _, code := range strings.Split(list, ",") {
+			if code = strings.TrimSpace(code); code == "" {
+				continue
+			}
+
+			check := lint.Lookup(code)
+			if check == nil {
+				return nil, fmt.Errorf("unknown check %q", code)
+			}
+			checks[check] = true
+		}
+
+		return checks, nil
+	}
+
+	enabled, err := lookup(enable)
+	if err != nil {
+		return nil, err
+	}
+
+	disabled, err := lookup(disable)
+	if err != nil {
+		return nil, err
+	}
+
+	var checks []*lint.Check
+	for _, check := range lint.Checks {
+		if (len(enabled) == 0 || enabled[check]) && !disabled[check] {
+			checks = append(checks, check)
+		}
+	}
+
+	return checks, nil
+}
+
+func runLsp(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) != 0 {
+		fmt.Fprintf(stderr, "usage: monkey lsp\n")
+		return exitUsage
+	}
+
+	if err := lsp.Serve(stdin, stdout); err != nil {
+		fmt.Fprintf(stderr, "monkey: %v\n", err)
+		return exitError
+	}
+
+	return exitOK
+}
+
+func runRename(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := newFlagSet("rename", stderr)
+	write := flags.Bool("w", false, "write the result to the file instead of stdout")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	if flags.NArg() != 2 {
+		fmt.Fprintf(stderr, "usage: monkey rename [-w] file.mk:line:col newName\n")
+		return exitUsage
+	}
+
+	path, pos, err := parseLocation(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "monkey: %v\n", err)
+		return exitUsage
+	}
+
+	source, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(stderr, "monkey: %v\n", err)
+		return exitError
+	}
+
+	offset, err := refactor.Offset(string(source), pos)
+	if err == nil {
+		var renamed string
+		if renamed, err = refactor.Rename(string(source), offset, flags.Arg(1)); err == nil {
+			source = []byte(renamed)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "monkey: %s:%s: %v\n", path, pos, err)
+		return exitError
+	}
+
+	if *write {
+		if err := os.WriteFile(path, source, 0o644); err != nil {
+			fmt.Fprintf(stderr, "monkey: %v\n", err)
+			return exitError
+		}
+		return exitOK
+	}
+
+	stdout.Write(source)
+	return exitOK
+}
+
+// Parses a `file.mk:line:col` location
+func parseLocation(location string) (string, token.Position, error) {
+	invalid := fmt.Errorf("invalid location %q, expected file.mk:line:col", location)
+
+	rest, colText, ok := cutLast(location, ":")
+	if !ok {
+		return "", token.Position{}, invalid
+	}
+
+	path, lineText, ok := cutLast(rest, ":")
+	if !ok || path == "" {
+		return "", token.Position{}, invalid
+	}
+
+	line, lineErr := strconv.Atoi(lineText)
+	col, colErr := strconv.Atoi(colText)
+	if lineErr != nil || colErr != nil {
+		return "", token.Position{}, invalid
+	}
+
+	return path, token.Position{Line: line, Column: col}, nil
+}
+
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+
+	return s, "", false
+}
+
+// Creates the flag set of a command, reporting flag errors on stderr
+func newFlagSet(name string, stderr io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet("monkey "+name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	return flags
+}
+
+// Reads the source file passed as the only argument of a command
+func readSourceArg(name string, args []string, stderr io.Writer) (string, int) {
+	if len(args) != 1 {
+		fmt.Fprintf(stderr, "usage: monkey %s file.mk\n", name)
+		return "", exitUsage
+	}
+
+	source, err := os.ReadFile(args[0])
+	if err != nil {
+		fmt.Fprintf(stderr, "monkey: %v\n", err)
+		return "", exitError
+	}
+
+	return string(source), exitOK
+}
+
+// Parses the source code, reporting any parser errors on stderr
+func parseSource(source string, stderr io.Writer) (*ast.Program, bool) {
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+
+	if errors := p.Errors(); len(errors) > 0 {
+		for _, err := range errors {
+			fmt.Fprintf(stderr, "monkey: %s\n", err)
+		}
+		return nil, false
+	}
 
-	repl.Start(os.Stdin, os.Stdout)
+	return program, true
 }
@@ -0,0 +1,156 @@
+// Package optimizer contains optimization passes over the AST of the monkey
+// programming language.
+//
+// The passes run between parsing and code generation and must never change
+// the meaning of a program, they only make it cheaper to execute.
+//
+// Constant folding evaluates expressions whose operands are all literals at
+// compile time, for example:
+// `2 * 3 + 1` becomes `7`
+// `!true` becomes `false`
+// `5 > 3 == true` becomes `true`
+// `2 ** 3 ** 2` becomes `512`
+package optimizer
+
+import (
+	"monkey/ast"
+	"monkey/token"
+	"strconv"
+)
+
+// Folds all constant expressions of the program in place
+func Fold(program *ast.Program) *ast.Program {
+	// Modify rewrites bottom-up, so the operands of an expression are
+	// already folded by the time the expression itself is visited
+	ast.Modify(program, func(node ast.Node) ast.Node {
+		switch node := node.(type) {
+		case *ast.PrefixExpression:
+			if folded := foldPrefix(node); folded != nil {
+				return folded
+			}
+		case *ast.InfixExpression:
+			if folded := foldInfix(node); folded != nil {
+				return folded
+			}
+		}
+
+		return node
+	})
+
+	return program
+}
+
+// Returns the folded prefix expression, or nil when it cannot be folded
+func foldPrefix(expression *ast.PrefixExpression) ast.Expression {
+	pos := expression.Token.Pos
+
+	switch value := expression.Value.(type) {
+	case *ast.IntegerLiteral:
+		switch expression.Operator {
+		case "-":
+			return newInteger(-value.Value, pos)
+		case "!":
+			// every integer is truthy
+			return newBoolean(false, pos)
+		}
+	case *ast.Boolean:
+		if expression.Operator == "!" {
+			return newBoolean(!value.Value, pos)
+		}
+	}
+
+	return nil
+}
+
+// Returns the folded infix expression, or nil when it cannot be folded
+func foldInfix(expression *ast.InfixExpression) ast.Expression {
+	switch left := expression.Left.(type) {
+	case *ast.IntegerLiteral:
+		right, ok := expression.Right.(*ast.IntegerLiteral)
+		if !ok {
+			return nil
+		}
+
+		return foldIntegerInfix(expression.Operator, left, right)
+	case *ast.Boolean:
+		right, ok := expression.Right.(*ast.Boolean)
+		if !ok {
+			return nil
+		}
+
+		switch expression.Operator {
+		case "==":
+			return newBoolean(left.Value == right.Value, left.Token.Pos)
+		case "!=":
+			return newBoolean(left.Value != right.Value, left.Token.Pos)
+		}
+	}
+
+	return nil
+}
+
+func foldIntegerInfix(operator string, left, right *ast.IntegerLiteral) ast.Expression {
+	pos := left.Token.Pos
+
+	switch operator {
+	case "+":
+		return newInteger(left.Value+right.Value, pos)
+	case "-":
+		return newInteger(left.Value-right.Value, pos)
+	case "*":
+		return newInteger(left.Value*right.Value, pos)
+	case "/":
+		// division by zero is a runtime error, leave it to the runtime
+		if right.Value == 0 {
+			return nil
+		}
+		return newInteger(left.Value/right.Value, pos)
+	case "**":
+		// negative exponents do not produce integers, leave it to the runtime
+		if right.Value < 0 {
+			return nil
+		}
+		return newInteger(power(left.Value, right.Value), pos)
+	case "<":
+		return newBoolean(left.Value < right.Value, pos)
+	case ">":
+		return newBoolean(left.Value > right.Value, pos)
+	case "==":
+		return newBoolean(left.Value == right.Value, pos)
+	case "!=":
+		return newBoolean(left.Value != right.Value, pos)
+	}
+
+	return nil
+}
+
+// Raises base to the non-negative exponent, by repeated squaring
+func power(base, exponent int64) int64 {
+	result := int64(1)
+
+	for exponent > 0 {
+		if exponent&1 == 1 {
+			result *= base
+		}
+		base *= base
+		exponent >>= 1
+	}
+
+	return result
+}
+
+func newInteger(value int64, pos token.Position) *ast.IntegerLiteral {
+	return &ast.IntegerLiteral{
+		Token: token.Token{Type: token.INT, Literal: strconv.FormatInt(value, 10), Pos: pos},
+		Value: value,
+	}
+}
+
+func newBoolean(value bool, pos token.Position) *ast.Boolean {
+	tok := token.Token{Type: token.FALSE, Literal: "false", Pos: pos}
+	if value {
+		tok = token.Token{Type: token.TRUE, Literal: "true", Pos: pos}
+	}
+
+	return &ast.Boolean{Token: tok, Value: value}
+}
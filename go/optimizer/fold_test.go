@@ -0,0 +1,53 @@
+package optimizer
+
+import (
+	"monkey/lexer"
+	"monkey/parser"
+	"testing"
+)
+
+func TestFold(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"2 * 3 + 1", "7"},
+		{"1 + 2 * 3", "7"},
+		{"10 - 2 - 3", "5"},
+		{"7 / 2", "3"},
+		{"-5", "-5"},
+		{"!true", "false"},
+		{"!!false", "false"},
+		{"!5", "false"},
+		{"5 > 3", "true"},
+		{"5 < 3 == false", "true"},
+		{"true != false", "true"},
+		{"let x = 2 * 21;", "let x = 42;"},
+		{"return 1 + 1;", "return 2;"},
+		// not constant, only the constant parts are folded
+		{"a + 2 * 3", "(a + 6)"},
+		{"a * 2 * 3", "((a * 2) * 3)"},
+		{"-a", "-a"},
+		{"1 + true", "(1 + true)"},
+		// division by zero must fail at runtime, not during compilation
+		{"1 / 0", "(1 / 0)"},
+		{"2 ** 3 ** 2", "512"},
+		{"(2 ** 3) ** 2", "64"},
+		{"-2 ** 2", "-4"},
+		{"5 ** 0", "1"},
+		{"2 ** -1", "(2 ** -1)"},
+	}
+
+	for _, testCase := range testCases {
+		p := parser.New(lexer.New(testCase.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			t.Fatalf("input %q: parser errors: %v", testCase.input, p.Errors())
+		}
+
+		actual := Fold(program).String()
+		if actual != testCase.expected {
+			t.Errorf("input %q: expected=%q, got=%q", testCase.input, testCase.expected, actual)
+		}
+	}
+}
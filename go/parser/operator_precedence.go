@@ -3,25 +3,63 @@ package parser
 import "monkey/token"
 
 // Operator precedence levels for the Monkey programming language
-// Ranges from 1 (lowest) - 7 (highest)
+// Ranges from 1 (lowest) - 10 (highest)
 const (
 	_ int = iota
 	LOWEST
+	CONDITIONAL // X ? Y : Z
+	PIPE        // |>, its right operand is only a call chain: X |> f(Y) + 1 is f(X, Y) + 1
 	EQUALS      // =
 	LESSGREATER // < or >
 	SUM         // +
 	PRODUCT     //*
 	PREFIX      // -X or !X
-	CALL        // myFunction(X)
+	POWER       // **, binds tighter than a prefix operator: -X ** Y is -(X ** Y)
+	CALL        // myFunction(X) or x.method(Y)
 )
 
-var precedenceMap = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
+// Whether a chain of operators of the same precedence groups to the left,
+// `a - b - c` is `(a - b) - c`, or to the right
+type Associativity int
+
+const (
+	LeftAssociative Associativity = iota
+	RightAssociative
+)
+
+// How an infix operator binds to its operands
+type Operator struct {
+	Precedence    int
+	Associativity Associativity
+}
+
+var precedenceMap = map[token.TokenType]Operator{
+	token.QUESTION: {CONDITIONAL, RightAssociative}, // a ? b : c ? d : e
+	token.PIPE:     {PIPE, LeftAssociative},
+	token.EQ:       {EQUALS, LeftAssociative},
+	token.NOT_EQ:   {EQUALS, LeftAssociative},
+	token.LT:       {LESSGREATER, LeftAssociative},
+	token.GT:       {LESSGREATER, LeftAssociative},
+	token.PLUS:     {SUM, LeftAssociative},
+	token.MINUS:    {SUM, LeftAssociative},
+	token.SLASH:    {PRODUCT, LeftAssociative},
+	token.ASTERISK: {PRODUCT, LeftAssociative},
+	token.POWER:    {POWER, RightAssociative}, // 2 ** 3 ** 2 is 2 ** 9
+	token.LPAREN:   {CALL, LeftAssociative},
+	token.DOT:      {CALL, LeftAssociative},
+}
+
+// Returns the precedence level of an infix operator, or LOWEST for tokens
+// that are not infix operators
+func Precedence(tokenType token.TokenType) int {
+	if operator, ok := precedenceMap[tokenType]; ok {
+		return operator.Precedence
+	}
+
+	return LOWEST
+}
+
+// Reports whether the infix operator groups to the right
+func IsRightAssociative(tokenType token.TokenType) bool {
+	return precedenceMap[tokenType].Associativity == RightAssociative
 }
@@ -20,12 +20,23 @@ package parser
 import (
 	"fmt"
 	"log/slog"
+	"maps"
 	"monkey/ast"
 	"monkey/lexer"
 	"monkey/token"
 	"strconv"
 )
 
+// An error encountered while parsing, at the position of the offending token
+type Error struct {
+	Pos     token.Position
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Message)
+}
+
 type (
 	// parses prefix expressions, e.g. `++var`
 	prefixParseFn func() ast.Expression
@@ -35,35 +46,63 @@ type (
 )
 
 // The Parses uses the tokens produces by the lexer
-// this implementation only looks at the current, and next token (1 lookahead)
-// which means that we can only the type of node by looking at these 2 tokens
+// this implementation mostly looks at the current, and next token (1
+// lookahead) which means that we can determine the type of node by looking at
+// these 2 tokens. Rules that need more can look further ahead in the stream.
 type Parser struct {
-	l *lexer.Lexer
+	tokens lexer.TokenStream
 
-	errors []string // Holds any errors that occured during parsing
+	errors   []*Error       // Holds any errors that occured during parsing
+	comments []*ast.Comment // Comments skipped over while reading tokens
 
 	currentToken token.Token // The current token that the parser is consuming
 	peekToken    token.Token // The next token, used for 1 node lookahead
 
 	prefixParseMap map[token.TokenType]prefixParseFn
 	infixParseMap  map[token.TokenType]infixParseFn
+
+	// the infix operators, the builtin ones and those registered with
+	// RegisterInfixOperator
+	operators map[token.TokenType]Operator
+
+	spans map[ast.Node]Span // source spans of the parsed nodes, nil unless tracked
+
+	tracer *tracer // writes the parse function events, nil unless tracing
+}
+
+// The source code covered by a node, from the start of its first token up to
+// the end of its last token, including tokens that are not part of the AST
+// such as parenthesis and semicolons
+type Span struct {
+	Start token.Position
+	End   token.Position // the position just after the last token
 }
 
 func New(l *lexer.Lexer) *Parser {
+	return NewFromStream(lexer.NewTokenStream(l))
+}
+
+// Creates a parser that consumes the tokens of the stream
+func NewFromStream(tokens lexer.TokenStream) *Parser {
 	slog.Debug("Constructed a Parser")
 	parser := &Parser{
-		l:      l,
-		errors: []string{},
+		tokens: tokens,
+		errors: []*Error{},
 
 		prefixParseMap: make(map[token.TokenType]prefixParseFn),
 		infixParseMap:  make(map[token.TokenType]infixParseFn),
+
+		operators: maps.Clone(precedenceMap),
 	}
 
 	// prefix expressions
 	parser.registerPrefixParseFn(token.IDENT, parser.parseIdentifier)
 	parser.registerPrefixParseFn(token.INT, parser.parseIntegerLiteral)
+	parser.registerPrefixParseFn(token.TRUE, parser.parseBoolean)
+	parser.registerPrefixParseFn(token.FALSE, parser.parseBoolean)
 	parser.registerPrefixParseFn(token.BANG, parser.parsePrefixExpression)
 	parser.registerPrefixParseFn(token.MINUS, parser.parsePrefixExpression)
+	parser.registerPrefixParseFn(token.LPAREN, parser.parseGroupedExpression)
 
 	// infix expressions
 	parser.registerInfixParseFn(token.PLUS, parser.parseInfixExpression)
@@ -74,6 +113,11 @@ func New(l *lexer.Lexer) *Parser {
 	parser.registerInfixParseFn(token.NOT_EQ, parser.parseInfixExpression)
 	parser.registerInfixParseFn(token.LT, parser.parseInfixExpression)
 	parser.registerInfixParseFn(token.GT, parser.parseInfixExpression)
+	parser.registerInfixParseFn(token.LPAREN, parser.parseCallExpression)
+	parser.registerInfixParseFn(token.DOT, parser.parseMethodCallExpression)
+	parser.registerInfixParseFn(token.PIPE, parser.parsePipeExpression)
+	parser.registerInfixParseFn(token.POWER, parser.parseInfixExpression)
+	parser.registerInfixParseFn(token.QUESTION, parser.parseConditionalExpression)
 
 	// reads the first two tokens such that
 	// currentToken and peekToken are set
@@ -91,9 +135,58 @@ func (p *Parser) registerInfixParseFn(tokenType token.TokenType, fn infixParseFn
 	p.infixParseMap[tokenType] = fn
 }
 
+// Adds a prefix operator to this parser, e.g. `#` in `#list`
+// The operator is parsed like `-` and `!`, into an ast.PrefixExpression.
+// Operators that the lexer does not know yet must be registered with
+// lexer.Lexer.RegisterOperator before the parser is created.
+func (p *Parser) RegisterPrefixOperator(tokenType token.TokenType) {
+	p.registerPrefixParseFn(tokenType, p.parsePrefixExpression)
+}
+
+// Adds an infix operator to this parser, e.g. `|>` in `x |> f`
+// The operator is parsed into an ast.InfixExpression, binding as tightly as
+// the builtin operators of the same precedence level. Registering a builtin
+// operator changes its precedence and associativity for this parser only.
+// Operators that the lexer does not know yet must be registered with
+// lexer.Lexer.RegisterOperator before the parser is created.
+func (p *Parser) RegisterInfixOperator(tokenType token.TokenType, precedence int, associativity Associativity) {
+	p.registerInfixParseFn(tokenType, p.parseInfixExpression)
+	p.operators[tokenType] = Operator{precedence, associativity}
+}
+
+// Advances to the next token
+// Comments are not part of the grammar, they are collected on the side such
+// that tools like the formatter can put them back
 func (p *Parser) nextToken() {
 	p.currentToken = p.peekToken
-	p.peekToken = p.l.NextToken()
+	p.peekToken = p.tokens.Next()
+
+	for p.peekToken.Type == token.COMMENT {
+		p.comments = append(p.comments, &ast.Comment{
+			Token: p.peekToken,
+			Text:  p.peekToken.Literal,
+		})
+		p.peekToken = p.tokens.Next()
+	}
+}
+
+// Returns the token n positions after the current token without consuming
+// anything, comments are skipped. peekTokenAt(1) is the peek token.
+func (p *Parser) peekTokenAt(n int) token.Token {
+	if n <= 1 {
+		return p.peekToken
+	}
+
+	for i := 0; ; i++ {
+		tok := p.tokens.Peek(i)
+		if tok.Type == token.COMMENT {
+			continue
+		}
+
+		if n--; n == 1 || tok.Type == token.EOF {
+			return tok
+		}
+	}
 }
 
 // check if the current token matches the expected type
@@ -118,23 +211,41 @@ func (p *Parser) expectPeek(tokenType token.TokenType) bool {
 }
 
 func (p *Parser) peekError(tokenType token.TokenType) {
-	err := fmt.Sprintf("Expected next token to be %q, received: %q",
-		tokenType, p.peekToken)
+	p.addError(p.peekToken.Pos, "Expected next token to be %q, received: %q",
+		tokenType, p.peekToken.Literal)
+}
 
-	p.errors = append(p.errors, err)
+func (p *Parser) addError(pos token.Position, format string, args ...any) {
+	p.errors = append(p.errors, &Error{
+		Pos:     pos,
+		Message: fmt.Sprintf(format, args...),
+	})
 }
 
 func (p *Parser) currentPrecedence() int {
-	if level, ok := precedenceMap[p.currentToken.Type]; ok {
-		return level
+	return p.precedence(p.currentToken.Type)
+}
+
+func (p *Parser) peekPrecedence() int {
+	return p.precedence(p.peekToken.Type)
+}
+
+// The level up to which the right operand of the current operator is
+// parsed. The operand takes operators of a higher precedence, and of the same
+// precedence when they group to the right: `a ** (b ** c)`
+func (p *Parser) rightOperandPrecedence() int {
+	operator := p.operators[p.currentToken.Type]
+	if operator.Associativity == RightAssociative {
+		return operator.Precedence - 1
 	}
 
-	return LOWEST
+	return operator.Precedence
 }
 
-func (p *Parser) peekPrecedence() int {
-	if level, ok := precedenceMap[p.peekToken.Type]; ok {
-		return level
+// Like Precedence, including the operators registered with this parser
+func (p *Parser) precedence(tokenType token.TokenType) int {
+	if operator, ok := p.operators[tokenType]; ok {
+		return operator.Precedence
 	}
 
 	return LOWEST
@@ -152,32 +263,82 @@ func (p *Parser) ParseProgram() *ast.Program {
 	// Look through all tokens of the lexer, try to produce statements
 	for p.currentToken.Type != token.EOF {
 		slog.Debug("ParseProgram", "token", p.currentToken)
+		start := p.currentToken
 		statement := p.parseStatement()
 
 		if statement != nil {
+			p.recordSpan(statement, start)
 			program.Statements = append(program.Statements, statement)
 		}
 
 		p.nextToken()
 	}
 
+	program.Comments = p.comments
+
 	return program
 }
 
+// Makes the parser record the span of every statement and expression it
+// parses, as needed by the concrete syntax tree. Must be called before
+// ParseProgram.
+func (p *Parser) TrackSpans() {
+	p.spans = map[ast.Node]Span{}
+}
+
+// Returns the recorded spans, see TrackSpans
+func (p *Parser) Spans() map[ast.Node]Span {
+	return p.spans
+}
+
+// Records that the node runs from the start token up to the current token
+// A node is recorded again when it turns out to be larger, e.g. when it is
+// wrapped in parenthesis
+func (p *Parser) recordSpan(node ast.Node, start token.Token) {
+	if p.spans == nil || node == nil {
+		return
+	}
+
+	p.spans[node] = Span{
+		Start: start.Pos,
+		End: token.Position{
+			Offset: p.currentToken.Pos.Offset + len(p.currentToken.Literal),
+			Line:   p.currentToken.Pos.Line,
+			Column: p.currentToken.Pos.Column + len(p.currentToken.Literal),
+		},
+	}
+}
+
 // Returns a list of errors the parser encoutered
 func (p *Parser) Errors() []string {
+	errors := make([]string, len(p.errors))
+	for i, err := range p.errors {
+		errors[i] = err.Error()
+	}
+
+	return errors
+}
+
+// Returns the errors the parser encountered, including their position
+func (p *Parser) ErrorList() []*Error {
 	return p.errors
 }
 
 // Tries parsing a statement based on the current token
 func (p *Parser) parseStatement() ast.Statement {
+	defer p.untrace(p.trace("parseStatement"))
+
 	slog.Debug(
 		"Parser - parseStatement",
 		"token", p.currentToken,
 	)
 	switch p.currentToken.Type {
 	case token.LET:
-		return p.parseLetStatement()
+		// avoid wrapping a nil *ast.LetStatement in a non-nil interface
+		if statement := p.parseLetStatement(); statement != nil {
+			return statement
+		}
+		return nil
 	case token.RETURN:
 		return p.parseReturnStatement()
 	default:
@@ -185,8 +346,10 @@ func (p *Parser) parseStatement() ast.Statement {
 	}
 }
 
-// let five = 5;
+// let five = 5; let five: int = 5;
 func (p *Parser) parseLetStatement() *ast.LetStatement {
+	defer p.untrace(p.trace("parseLetStatement"))
+
 	statement := &ast.LetStatement{
 		Token: p.currentToken,
 	}
@@ -200,6 +363,20 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 		Identifier: p.currentToken.Literal,
 	}
 
+	// optional type annotation, `let five: int = 5;`
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+
+		statement.Type = &ast.TypeName{
+			Token: p.currentToken,
+			Name:  p.currentToken.Literal,
+		}
+	}
+
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
 	}
@@ -207,8 +384,7 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	p.nextToken()
 	statement.Value = p.parseExpression(LOWEST)
 
-	// Consume all tokens up to the semicolon
-	for !p.currentTokenIs(token.SEMICOLON) {
+	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 
@@ -222,6 +398,8 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 
 // return 5; return myFunctionCall(2, 4);
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
+	defer p.untrace(p.trace("parseReturnStatement"))
+
 	statement := &ast.ReturnStatement{
 		Token: p.currentToken,
 	}
@@ -230,7 +408,7 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 
 	statement.Expression = p.parseExpression(LOWEST)
 
-	for !p.currentTokenIs(token.SEMICOLON) {
+	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 
@@ -242,6 +420,8 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 }
 
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
+	defer p.untrace(p.trace("parseExpressionStatement"))
+
 	statement := &ast.ExpressionStatement{
 		Token: p.currentToken,
 	}
@@ -257,14 +437,18 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 }
 
 func (p *Parser) parseExpression(precedenceLevel int) ast.Expression {
+	defer p.untrace(p.traceLevel("parseExpression", precedenceLevel))
+
 	prefixParser := p.prefixParseMap[p.currentToken.Type]
 
 	if prefixParser == nil {
-		p.errors = append(p.errors, fmt.Sprintf("No prefix parse function found for %q", p.currentToken.Literal))
+		p.addError(p.currentToken.Pos, "No prefix parse function found for %q", p.currentToken.Literal)
 		return nil
 	}
 
+	start := p.currentToken
 	leftExpression := prefixParser()
+	p.recordSpan(leftExpression, start)
 
 	for !p.peekTokenIs(token.SEMICOLON) && precedenceLevel < p.peekPrecedence() {
 		// should parse infix expression
@@ -276,12 +460,15 @@ func (p *Parser) parseExpression(precedenceLevel int) ast.Expression {
 		p.nextToken()
 
 		leftExpression = infixParser(leftExpression)
+		p.recordSpan(leftExpression, start)
 	}
 
 	return leftExpression
 }
 
 func (p *Parser) parseIdentifier() ast.Expression {
+	defer p.untrace(p.trace("parseIdentifier"))
+
 	return &ast.Identifier{
 		Token:      p.currentToken,
 		Identifier: p.currentToken.Literal,
@@ -290,6 +477,8 @@ func (p *Parser) parseIdentifier() ast.Expression {
 
 // Parses integer literals e.g.: `5`
 func (p *Parser) parseIntegerLiteral() ast.Expression {
+	defer p.untrace(p.trace("parseIntegerLiteral"))
+
 	val, err := strconv.ParseInt(p.currentToken.Literal, 10, 64)
 	if err != nil {
 		slog.Error("parseIntegerLiteral error",
@@ -297,7 +486,7 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 			"tokenLiteral", p.currentToken.Literal,
 			"tokenType", p.currentToken.Type,
 		)
-		p.errors = append(p.errors, err.Error())
+		p.addError(p.currentToken.Pos, "%s", err)
 
 		return nil
 	}
@@ -308,8 +497,20 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	}
 }
 
+// Parses boolean literals: `true` and `false`
+func (p *Parser) parseBoolean() ast.Expression {
+	defer p.untrace(p.trace("parseBoolean"))
+
+	return &ast.Boolean{
+		Token: p.currentToken,
+		Value: p.currentTokenIs(token.TRUE),
+	}
+}
+
 // Parsing prefix expressions, e.g. `-5`
 func (p *Parser) parsePrefixExpression() ast.Expression {
+	defer p.untrace(p.trace("parsePrefixExpression"))
+
 	expression := &ast.PrefixExpression{
 		Token:    p.currentToken,
 		Operator: p.currentToken.Literal,
@@ -322,18 +523,155 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 	return expression
 }
 
+// Parses expressions wrapped in parenthesis, e.g. `(5 + 5)`
+// The parenthesis only influence precedence, they do not produce a node
+func (p *Parser) parseGroupedExpression() ast.Expression {
+	defer p.untrace(p.trace("parseGroupedExpression"))
+
+	p.nextToken()
+
+	expression := p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	return expression
+}
+
 // Parsing infix expressions, e.g. `5 + 5`
 func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
+	defer p.untrace(p.trace("parseInfixExpression"))
+
 	expression := &ast.InfixExpression{
 		Token:    p.currentToken,
 		Left:     left,
 		Operator: p.currentToken.Literal,
 	}
 
-	precedenceLevel := p.currentPrecedence()
+	precedenceLevel := p.rightOperandPrecedence()
 	p.nextToken()
 
 	expression.Right = p.parseExpression(precedenceLevel)
 
 	return expression
 }
+
+// Parses function calls, e.g. `add(1, 2)`
+func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
+	defer p.untrace(p.trace("parseCallExpression"))
+
+	expression := &ast.CallExpression{
+		Token:    p.currentToken,
+		Function: function,
+	}
+
+	arguments, ok := p.parseCallArguments()
+	if !ok {
+		return nil
+	}
+	expression.Arguments = arguments
+
+	return expression
+}
+
+// Parses method calls, e.g. `list.map(double)`
+func (p *Parser) parseMethodCallExpression(receiver ast.Expression) ast.Expression {
+	defer p.untrace(p.trace("parseMethodCallExpression"))
+
+	expression := &ast.MethodCallExpression{
+		Token:    p.currentToken,
+		Receiver: receiver,
+	}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	expression.Method = &ast.Identifier{
+		Token:      p.currentToken,
+		Identifier: p.currentToken.Literal,
+	}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	arguments, ok := p.parseCallArguments()
+	if !ok {
+		return nil
+	}
+	expression.Arguments = arguments
+
+	return expression
+}
+
+// Parses the comma separated arguments of a call, from the current `(` up
+// to and including the closing `)`
+func (p *Parser) parseCallArguments() ([]ast.Expression, bool) {
+	var arguments []ast.Expression
+
+	if p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		return arguments, true
+	}
+
+	p.nextToken()
+	arguments = append(arguments, p.parseExpression(LOWEST))
+
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		p.nextToken()
+		arguments = append(arguments, p.parseExpression(LOWEST))
+	}
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil, false
+	}
+
+	return arguments, true
+}
+
+// Parses pipes, e.g. `x |> f(y)`
+func (p *Parser) parsePipeExpression(left ast.Expression) ast.Expression {
+	defer p.untrace(p.trace("parsePipeExpression"))
+
+	expression := &ast.PipeExpression{
+		Token: p.currentToken,
+		Left:  left,
+	}
+
+	p.nextToken()
+
+	// the right operand is the function to call, such as `f`, `f(y)` or
+	// `list.map(f)`, so only calls and method calls bind to it. The
+	// operators that follow apply to the result of the pipe.
+	expression.Right = p.parseExpression(CALL - 1)
+
+	return expression
+}
+
+// Parses conditional expressions, e.g. `a > b ? a : b`
+func (p *Parser) parseConditionalExpression(condition ast.Expression) ast.Expression {
+	defer p.untrace(p.trace("parseConditionalExpression"))
+
+	expression := &ast.ConditionalExpression{
+		Token:     p.currentToken,
+		Condition: condition,
+	}
+
+	precedenceLevel := p.rightOperandPrecedence()
+	p.nextToken()
+
+	// the consequence is delimited by the colon, it can be any expression
+	expression.Consequence = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.COLON) {
+		return nil
+	}
+
+	p.nextToken()
+	expression.Alternative = p.parseExpression(precedenceLevel)
+
+	return expression
+}
@@ -3,6 +3,8 @@ package parser
 import (
 	"monkey/ast"
 	"monkey/lexer"
+	"monkey/token"
+	"strings"
 	"testing"
 )
 
@@ -41,6 +43,149 @@ let foobar = 8383838;
 	}
 }
 
+func TestLetStatementTypeAnnotation(t *testing.T) {
+	testCases := []struct {
+		input        string
+		expectedType string
+		expected     string
+	}{
+		{"let x: int = 5;", "int", "let x: int = 5;"},
+		{"let ok: bool = 1 < 2;", "bool", "let ok: bool = (1 < 2);"},
+		{"let x = 5;", "", "let x = 5;"},
+	}
+
+	for _, testCase := range testCases {
+		parse := New(lexer.New(testCase.input))
+		program := parse.ParseProgram()
+		checkParserErrors(t, parse)
+
+		statement, ok := program.Statements[0].(*ast.LetStatement)
+		if !ok {
+			t.Fatalf("Statement was not a LetStatement. got=%T", program.Statements[0])
+		}
+
+		actualType := ""
+		if statement.Type != nil {
+			actualType = statement.Type.Name
+		}
+
+		if actualType != testCase.expectedType {
+			t.Errorf("Expected type annotation %q, got=%q", testCase.expectedType, actualType)
+		}
+
+		if program.String() != testCase.expected {
+			t.Errorf("expected=%q, got=%q", testCase.expected, program.String())
+		}
+	}
+
+	parse := New(lexer.New("let x: = 5;"))
+	parse.ParseProgram()
+	if len(parse.Errors()) == 0 {
+		t.Errorf("Expected an error for a missing type name")
+	}
+}
+
+func TestParseFromTokens(t *testing.T) {
+	tokens := []token.Token{
+		{Type: token.LET, Literal: "let"},
+		{Type: token.IDENT, Literal: "x"},
+		{Type: token.ASSIGN, Literal: "="},
+		{Type: token.INT, Literal: "1"},
+		{Type: token.PLUS, Literal: "+"},
+		{Type: token.INT, Literal: "2"},
+		{Type: token.SEMICOLON, Literal: ";"},
+	}
+
+	parse := NewFromStream(lexer.FromTokens(tokens))
+	program := parse.ParseProgram()
+	checkParserErrors(t, parse)
+
+	if program.String() != "let x = (1 + 2);" {
+		t.Errorf("expected=%q, got=%q", "let x = (1 + 2);", program.String())
+	}
+}
+
+func TestPeekTokenAt(t *testing.T) {
+	parse := New(lexer.New("a + // comment\n b;"))
+
+	expected := []token.TokenType{token.PLUS, token.IDENT, token.SEMICOLON, token.EOF, token.EOF}
+	for i, tokenType := range expected {
+		if tok := parse.peekTokenAt(i + 1); tok.Type != tokenType {
+			t.Errorf("peekTokenAt(%d): expected=%q, got=%q", i+1, tokenType, tok.Type)
+		}
+	}
+
+	if parse.currentToken.Literal != "a" {
+		t.Errorf("peeking must not consume tokens, current token is %q", parse.currentToken.Literal)
+	}
+}
+
+func TestSpans(t *testing.T) {
+	input := "let x = (1 + 2) * 3;\n-a"
+
+	parse := New(lexer.New(input))
+	parse.TrackSpans()
+	program := parse.ParseProgram()
+	checkParserErrors(t, parse)
+
+	source := func(node ast.Node) string {
+		span, ok := parse.Spans()[node]
+		if !ok {
+			t.Fatalf("no span recorded for %s", node)
+		}
+		return input[span.Start.Offset:span.End.Offset]
+	}
+
+	let := program.Statements[0].(*ast.LetStatement)
+	product := let.Value.(*ast.InfixExpression)
+	prefix := program.Statements[1].(*ast.ExpressionStatement).Expression
+
+	testCases := []struct {
+		node     ast.Node
+		expected string
+	}{
+		{let, "let x = (1 + 2) * 3;"},
+		{product, "(1 + 2) * 3"},
+		{product.Left, "(1 + 2)"},
+		{product.Right, "3"},
+		{program.Statements[1], "-a"},
+		{prefix, "-a"},
+	}
+
+	for _, testCase := range testCases {
+		if actual := source(testCase.node); actual != testCase.expected {
+			t.Errorf("expected span %q, got=%q", testCase.expected, actual)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `
+// the answer
+let x = 42; // trailing
+x;
+// at the end`
+
+	parse := New(lexer.New(input))
+	program := parse.ParseProgram()
+	checkParserErrors(t, parse)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("Expected 2 statements, got: %d", len(program.Statements))
+	}
+
+	expected := []string{"// the answer", "// trailing", "// at the end"}
+	if len(program.Comments) != len(expected) {
+		t.Fatalf("Expected %d comments, got: %d", len(expected), len(program.Comments))
+	}
+
+	for i, comment := range program.Comments {
+		if comment.Text != expected[i] {
+			t.Errorf("comment[%d]: expected=%q, got=%q", i, expected[i], comment.Text)
+		}
+	}
+}
+
 func checkParserErrors(t *testing.T, p *Parser) {
 	errors := p.Errors()
 
@@ -57,6 +202,95 @@ func checkParserErrors(t *testing.T, p *Parser) {
 	t.FailNow()
 }
 
+func TestTrace(t *testing.T) {
+	var out strings.Builder
+
+	parse := New(lexer.New("-a;"))
+	parse.Trace(&out)
+	parse.ParseProgram()
+	checkParserErrors(t, parse)
+
+	expected := `BEGIN parseStatement current="-" peek="a" (LOWEST)
+	BEGIN parseExpressionStatement current="-" peek="a" (LOWEST)
+		BEGIN parseExpression(LOWEST) current="-" peek="a" (LOWEST)
+			BEGIN parsePrefixExpression current="-" peek="a" (LOWEST)
+				BEGIN parseExpression(PREFIX) current="a" peek=";" (LOWEST)
+					BEGIN parseIdentifier current="a" peek=";" (LOWEST)
+					END parseIdentifier current="a" peek=";" (LOWEST)
+				END parseExpression current="a" peek=";" (LOWEST)
+			END parsePrefixExpression current="a" peek=";" (LOWEST)
+		END parseExpression current="a" peek=";" (LOWEST)
+	END parseExpressionStatement current=";" peek=EOF (LOWEST)
+END parseStatement current=";" peek=EOF (LOWEST)
+`
+
+	if out.String() != expected {
+		t.Errorf("wrong trace.\nexpected=%s\ngot=%s", expected, out.String())
+	}
+
+	// tracing is off by default, and can be turned off again
+	out.Reset()
+	parse = New(lexer.New("-a;"))
+	parse.Trace(&out)
+	parse.Trace(nil)
+	parse.ParseProgram()
+
+	if out.Len() != 0 {
+		t.Errorf("expected no trace when tracing is off, got=%s", out.String())
+	}
+}
+
+func TestRegisterOperators(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"x |> f |> g", "((x |> f) |> g)"},
+		{"x + 1 |> f", "((x + 1) |> f)"},
+		{"a ^ b ^ c * d", "(a ^ (b ^ (c * d)))"},
+		{"#a ^ b", "(#a ^ b)"},
+		{"-#a", "-#a"},
+		// builtin operators can be changed per parser
+		{"a - b - c", "(a - (b - c))"},
+	}
+
+	for _, testCase := range testCases {
+		l := lexer.New(testCase.input)
+		l.RegisterOperator("|>")
+		l.RegisterOperator("^")
+		l.RegisterOperator("#")
+
+		parse := New(l)
+		parse.RegisterInfixOperator("|>", PIPE, LeftAssociative)
+		parse.RegisterInfixOperator("^", SUM, RightAssociative)
+		parse.RegisterInfixOperator(token.MINUS, SUM, RightAssociative)
+		parse.RegisterPrefixOperator("#")
+
+		program := parse.ParseProgram()
+		checkParserErrors(t, parse)
+
+		if actual := program.String(); actual != testCase.expected {
+			t.Errorf("input %q: expected=%q, got=%q", testCase.input, testCase.expected, actual)
+		}
+	}
+
+	// registering operators does not affect other parsers
+	parse := New(lexer.New("a - b - c"))
+	if actual := parse.ParseProgram().String(); actual != "((a - b) - c)" {
+		t.Errorf("expected the builtin associativity, got=%q", actual)
+	}
+}
+
+func TestConditionalExpressionErrors(t *testing.T) {
+	parse := New(lexer.New("a ? b c"))
+	parse.ParseProgram()
+
+	expected := []string{`1:7: Expected next token to be ":", received: "c"`}
+	if errors := parse.Errors(); len(errors) == 0 || errors[0] != expected[0] {
+		t.Errorf("expected=%q, got=%q", expected, errors)
+	}
+}
+
 func TestReturnStatement(t *testing.T) {
 	input := `
   return 5;
@@ -165,6 +399,41 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestBooleanExpression(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected bool
+	}{
+		{"true;", true},
+		{"false;", false},
+	}
+
+	for _, testCase := range testCases {
+		parser := New(lexer.New(testCase.input))
+
+		program := parser.ParseProgram()
+		checkParserErrors(t, parser)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("Expected one statement, got=`%d`", len(program.Statements))
+		}
+
+		statement, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("Expected statement to be an ExpressionStatement, got=`%T`", program.Statements[0])
+		}
+
+		boolean, ok := statement.Expression.(*ast.Boolean)
+		if !ok {
+			t.Fatalf("Expected expression to be an ast.Boolean, got=`%T`", statement.Expression)
+		}
+
+		if boolean.Value != testCase.expected {
+			t.Fatalf("Expected boolean's value to equal `%t`, got=`%t`", testCase.expected, boolean.Value)
+		}
+	}
+}
+
 func TestPrefixExpressions(t *testing.T) {
 	testCases := []struct {
 		input        string
@@ -392,6 +661,78 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"n.add(a + b + c * d / f + g)",
 			"n.add((((a + b) + ((c * d) / f)) + g))",
 		},
+		{
+			"add(a, b)(c)",
+			"add(a, b)(c)",
+		},
+		{
+			"-a.abs()",
+			"-a.abs()",
+		},
+		{
+			"a.b().c(d)",
+			"a.b().c(d)",
+		},
+		{
+			"x |> f(y) |> g",
+			"((x |> f(y)) |> g)",
+		},
+		{
+			"a + b |> f == c",
+			"(((a + b) |> f) == c)",
+		},
+		{
+			"n * 2 |> show",
+			"((n * 2) |> show)",
+		},
+		{
+			"x |> f(y) + 1",
+			"((x |> f(y)) + 1)",
+		},
+		{
+			"x |> f(y) * 2 < 3",
+			"(((x |> f(y)) * 2) < 3)",
+		},
+		{
+			"-x |> f",
+			"(-x |> f)",
+		},
+		{
+			"2 ** 3 ** 2",
+			"(2 ** (3 ** 2))",
+		},
+		{
+			"(2 ** 3) ** 2",
+			"((2 ** 3) ** 2)",
+		},
+		{
+			"a * b ** c",
+			"(a * (b ** c))",
+		},
+		{
+			"-a ** b",
+			"-(a ** b)",
+		},
+		{
+			"a ** -b",
+			"(a ** -b)",
+		},
+		{
+			"a ? b : c",
+			"(a ? b : c)",
+		},
+		{
+			"a ? b : c ? d : e",
+			"(a ? b : (c ? d : e))",
+		},
+		{
+			"a ? b ? c : d : e",
+			"(a ? (b ? c : d) : e)",
+		},
+		{
+			"x > 0 ? x + 1 : -x",
+			"((x > 0) ? (x + 1) : -x)",
+		},
 	}
 
 	for _, testCase := range testCases {
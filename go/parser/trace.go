@@ -0,0 +1,108 @@
+package parser
+
+import (
+	"fmt"
+	"io"
+	"monkey/token"
+	"strings"
+)
+
+// Writes the BEGIN and END events of the parse functions, indented by how
+// deeply they are nested
+type tracer struct {
+	out   io.Writer
+	depth int
+}
+
+// Makes the parser write an event to w whenever a parse function begins or
+// ends, with the current and peek tokens and the precedence levels involved.
+// This shows how parseExpression climbs the precedence levels, e.g. for
+// `1 + 2 * 3` the multiplication is parsed within the right operand of `+`
+// (the enclosing statement events are left out):
+//
+//	BEGIN parseExpression(LOWEST) current="1" peek="+" (SUM)
+//		BEGIN parseIntegerLiteral current="1" peek="+" (SUM)
+//		END parseIntegerLiteral current="1" peek="+" (SUM)
+//		BEGIN parseInfixExpression current="+" peek="2" (LOWEST)
+//			BEGIN parseExpression(SUM) current="2" peek="*" (PRODUCT)
+//	...
+//
+// Tracing is off by default, pass nil to turn it off again. Must be called
+// before ParseProgram.
+func (p *Parser) Trace(w io.Writer) {
+	if w == nil {
+		p.tracer = nil
+		return
+	}
+
+	p.tracer = &tracer{out: w}
+}
+
+// Writes the BEGIN event of the parse function and returns its name for the
+// END event, to be used as `defer p.untrace(p.trace("parseFoo"))`
+// Does nothing unless tracing is on.
+func (p *Parser) trace(name string) string {
+	if p.tracer != nil {
+		p.event("BEGIN " + name)
+		p.tracer.depth++
+	}
+
+	return name
+}
+
+// Like trace, for parse functions that parse up to a precedence level
+func (p *Parser) traceLevel(name string, precedenceLevel int) string {
+	if p.tracer != nil {
+		p.event(fmt.Sprintf("BEGIN %s(%s)", name, precedenceName(precedenceLevel)))
+		p.tracer.depth++
+	}
+
+	return name
+}
+
+// Writes the END event of the parse function
+func (p *Parser) untrace(name string) {
+	if p.tracer != nil {
+		p.tracer.depth--
+		p.event("END " + name)
+	}
+}
+
+func (p *Parser) event(event string) {
+	fmt.Fprintf(p.tracer.out, "%s%s current=%s peek=%s (%s)\n",
+		strings.Repeat("\t", p.tracer.depth),
+		event,
+		traceToken(p.currentToken),
+		traceToken(p.peekToken),
+		precedenceName(p.peekPrecedence()),
+	)
+}
+
+func traceToken(tok token.Token) string {
+	if tok.Type == token.EOF {
+		return "EOF"
+	}
+
+	return fmt.Sprintf("%q", tok.Literal)
+}
+
+var precedenceNames = map[int]string{
+	LOWEST:      "LOWEST",
+	CONDITIONAL: "CONDITIONAL",
+	PIPE:        "PIPE",
+	EQUALS:      "EQUALS",
+	LESSGREATER: "LESSGREATER",
+	SUM:         "SUM",
+	PRODUCT:     "PRODUCT",
+	PREFIX:      "PREFIX",
+	POWER:       "POWER",
+	CALL:        "CALL",
+}
+
+func precedenceName(precedenceLevel int) string {
+	if name, ok := precedenceNames[precedenceLevel]; ok {
+		return name
+	}
+
+	return fmt.Sprint(precedenceLevel)
+}
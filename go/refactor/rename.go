@@ -0,0 +1,168 @@
+// Package refactor implements automated, meaning preserving edits of monkey
+// source code.
+//
+// Edits are applied to the source text itself, such that the formatting and
+// the comments of the rest of the file are left untouched.
+package refactor
+
+import (
+	"errors"
+	"fmt"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/scope"
+	"monkey/token"
+	"sort"
+	"strings"
+)
+
+// Renames the binding of the identifier at the byte offset, together with
+// every reference to it, and returns the new source code.
+//
+// Only that binding is renamed: an earlier or later let statement with the
+// same name is a different binding. The rename is refused when it would
+// change what any identifier refers to, e.g. when the new name is already
+// bound in between the declaration and one of its references.
+func Rename(source string, offset int, newName string) (string, error) {
+	if !lexer.IsIdentifier(newName) {
+		return "", fmt.Errorf("%q is not a valid identifier", newName)
+	}
+
+	program, err := parse(source)
+	if err != nil {
+		return "", err
+	}
+
+	info := scope.Resolve(program)
+	binding, _ := info.BindingAt(offset)
+	if binding == nil {
+		return "", errors.New("no let binding or reference at this position")
+	}
+
+	if binding.Name.Identifier == newName {
+		return source, nil
+	}
+
+	identifiers := append([]*ast.Identifier{binding.Name}, binding.References...)
+	renamed := replaceIdentifiers(source, identifiers, newName)
+
+	// renaming must not change how any identifier resolves
+	renamedProgram, err := parse(renamed)
+	if err != nil {
+		return "", fmt.Errorf("renamed source does not parse: %w", err)
+	}
+
+	if conflict := compareResolution(program, info, renamedProgram, scope.Resolve(renamedProgram)); conflict != nil {
+		return "", fmt.Errorf("renaming %s to %s would change what %s at %s refers to",
+			binding.Name.Identifier, newName, conflict.Identifier, conflict.Pos())
+	}
+
+	return renamed, nil
+}
+
+func parse(source string) (*ast.Program, error) {
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+
+	if len(p.Errors()) > 0 {
+		return nil, errors.New(strings.Join(p.Errors(), "\n"))
+	}
+
+	return program, nil
+}
+
+// Replaces the identifiers in the source code with the new name
+func replaceIdentifiers(source string, identifiers []*ast.Identifier, newName string) string {
+	sorted := append([]*ast.Identifier(nil), identifiers...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Pos().Offset < sorted[j].Pos().Offset
+	})
+
+	var out strings.Builder
+	end := 0
+	for _, identifier := range sorted {
+		start := identifier.Pos().Offset
+		out.WriteString(source[end:start])
+		out.WriteString(newName)
+		end = start + len(identifier.Identifier)
+	}
+	out.WriteString(source[end:])
+
+	return out.String()
+}
+
+// Compares how the identifiers of two versions of a program resolve, and
+// returns the first identifier of the original that resolves differently
+//
+// A rename does not add or remove identifiers, so the n-th identifier of
+// both versions is the same identifier. It must refer to the n-th binding in
+// both, or be undefined in both.
+func compareResolution(before *ast.Program, beforeInfo *scope.Info, after *ast.Program, afterInfo *scope.Info) *ast.Identifier {
+	beforeIdentifiers := identifiersOf(before)
+	afterIdentifiers := identifiersOf(after)
+
+	bindingIndex := func(info *scope.Info, identifier *ast.Identifier) int {
+		binding, ok := info.Identifiers[identifier]
+		if !ok {
+			return -1
+		}
+
+		for i, b := range info.Bindings {
+			if b == binding {
+				return i
+			}
+		}
+
+		return -1
+	}
+
+	for i, identifier := range beforeIdentifiers {
+		if bindingIndex(beforeInfo, identifier) != bindingIndex(afterInfo, afterIdentifiers[i]) {
+			return identifier
+		}
+	}
+
+	return nil
+}
+
+// Returns the identifiers of the program in source order
+func identifiersOf(program *ast.Program) []*ast.Identifier {
+	var identifiers []*ast.Identifier
+
+	ast.Inspect(program, func(node ast.Node) bool {
+		if identifier, ok := node.(*ast.Identifier); ok {
+			identifiers = append(identifiers, identifier)
+		}
+		return true
+	})
+
+	return identifiers
+}
+
+// Converts a 1-based line and column into a byte offset in the source code
+func Offset(source string, pos token.Position) (int, error) {
+	if pos.Line < 1 || pos.Column < 1 {
+		return 0, fmt.Errorf("invalid position %s", pos)
+	}
+
+	offset := 0
+	for line := 1; line < pos.Line; line++ {
+		newline := strings.IndexByte(source[offset:], '\n')
+		if newline == -1 {
+			return 0, fmt.Errorf("position %s is past the end of the file", pos)
+		}
+		offset += newline + 1
+	}
+
+	lineEnd := strings.IndexByte(source[offset:], '\n')
+	if lineEnd == -1 {
+		lineEnd = len(source) - offset
+	}
+
+	if pos.Column-1 > lineEnd {
+		return 0, fmt.Errorf("position %s is past the end of the line", pos)
+	}
+
+	return offset + pos.Column - 1, nil
+}
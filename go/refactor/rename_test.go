@@ -0,0 +1,125 @@
+package refactor
+
+import (
+	"monkey/token"
+	"strings"
+	"testing"
+)
+
+func TestRename(t *testing.T) {
+	testCases := []struct {
+		input    string
+		at       string // the rename happens at the first occurrence of this marker
+		newName  string
+		expected string
+	}{
+		{
+			"let x = 1;\nlet y = x + x;   // keep this comment\ny;",
+			"x =",
+			"count",
+			"let count = 1;\nlet y = count + count;   // keep this comment\ny;",
+		},
+		{
+			// renaming from a reference renames the declaration too
+			"let x = 1;\nx * 2;",
+			"x *",
+			"value",
+			"let value = 1;\nvalue * 2;",
+		},
+		{
+			// only the binding that the identifier refers to is renamed
+			"let x = 1;\nlet y = x;\nlet x = 2;\nx + y;",
+			"x + y",
+			"second",
+			"let x = 1;\nlet y = x;\nlet second = 2;\nsecond + y;",
+		},
+		{
+			"let x = 1;\nlet y = x;\nlet x = x + 1;\nx + y;",
+			"x = 1",
+			"first",
+			"let first = 1;\nlet y = first;\nlet x = first + 1;\nx + y;",
+		},
+	}
+
+	for _, testCase := range testCases {
+		offset := strings.Index(testCase.input, testCase.at)
+
+		actual, err := Rename(testCase.input, offset, testCase.newName)
+		if err != nil {
+			t.Fatalf("input %q: unexpected error: %v", testCase.input, err)
+		}
+
+		if actual != testCase.expected {
+			t.Errorf("input %q:\nexpected=%q\ngot=%q", testCase.input, testCase.expected, actual)
+		}
+	}
+}
+
+func TestRenameErrors(t *testing.T) {
+	testCases := []struct {
+		input   string
+		offset  int
+		newName string
+		err     string
+	}{
+		{"let x = 1; x;", 4, "1x", "not a valid identifier"},
+		{"let x = 1; x;", 4, "let", "not a valid identifier"},
+		{"let x = 1; x;", 0, "y", "no let binding"},
+		{"let x = ; x;", 4, "y", "No prefix parse function"},
+		{
+			// y would now refer to the renamed binding instead of the first y
+			"let y = 1;\nlet x = 2;\nx + y;",
+			len("let y = 1;\nlet "),
+			"y",
+			"would change what y at 3:5 refers to",
+		},
+		{
+			// the reference of x would be captured by the later y
+			"let x = 1;\nlet y = 2;\nx + y;",
+			len("let "),
+			"y",
+			"would change what x at 3:1 refers to",
+		},
+		{
+			// z is undefined, renaming x to z would bind it
+			"let x = 1;\nx + z;",
+			len("let "),
+			"z",
+			"would change what z at 2:5 refers to",
+		},
+	}
+
+	for _, testCase := range testCases {
+		_, err := Rename(testCase.input, testCase.offset, testCase.newName)
+
+		if err == nil || !strings.Contains(err.Error(), testCase.err) {
+			t.Errorf("input %q: expected error containing %q, got=%v", testCase.input, testCase.err, err)
+		}
+	}
+}
+
+func TestOffset(t *testing.T) {
+	source := "let x = 1;\nx;"
+
+	testCases := []struct {
+		pos      token.Position
+		expected int
+		ok       bool
+	}{
+		{token.Position{Line: 1, Column: 1}, 0, true},
+		{token.Position{Line: 1, Column: 5}, 4, true},
+		{token.Position{Line: 2, Column: 1}, 11, true},
+		{token.Position{Line: 2, Column: 3}, 13, true},
+		{token.Position{Line: 2, Column: 4}, 0, false},
+		{token.Position{Line: 3, Column: 1}, 0, false},
+		{token.Position{Line: 0, Column: 1}, 0, false},
+	}
+
+	for _, testCase := range testCases {
+		actual, err := Offset(source, testCase.pos)
+
+		if (err == nil) != testCase.ok || actual != testCase.expected {
+			t.Errorf("Offset(%s): expected=%d (ok=%t), got=%d (err=%v)", testCase.pos, testCase.expected, testCase.ok, actual, err)
+		}
+	}
+}
@@ -5,16 +5,20 @@ import (
 	"fmt"
 	"io"
 	"monkey/lexer"
-	"monkey/token"
+	"monkey/parser"
 )
 
 const PROMPT = "=>"
 
+// Toggles the parser trace, see parser.Parser.Trace
+const TRACE = ":trace"
+
 func Start(in io.Reader, out io.Writer) {
 	scanner := bufio.NewScanner(in)
+	trace := false
 
 	for {
-		fmt.Print(PROMPT)
+		fmt.Fprint(out, PROMPT)
 
 		ok := scanner.Scan()
 		if !ok {
@@ -23,10 +27,38 @@ func Start(in io.Reader, out io.Writer) {
 
 		line := scanner.Text()
 
+		if line == TRACE {
+			trace = !trace
+			if trace {
+				fmt.Fprintln(out, "parser trace on")
+			} else {
+				fmt.Fprintln(out, "parser trace off")
+			}
+			continue
+		}
+
 		l := lexer.New(line)
 
-		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
-			fmt.Printf("%+v\n", tok)
+		if trace {
+			printTrace(l, out)
+			continue
+		}
+
+		for tok := range lexer.NewTokenStream(l).All() {
+			fmt.Fprintf(out, "%+v\n", tok)
 		}
 	}
 }
+
+// Parses the line while tracing the parser, followed by the parsed program
+func printTrace(l *lexer.Lexer, out io.Writer) {
+	p := parser.New(l)
+	p.Trace(out)
+	program := p.ParseProgram()
+
+	for _, err := range p.Errors() {
+		fmt.Fprintf(out, "error: %s\n", err)
+	}
+
+	fmt.Fprintln(out, program.String())
+}
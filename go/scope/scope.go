@@ -0,0 +1,96 @@
+// Package scope resolves the identifiers of a monkey program to the let
+// statements that bind them.
+//
+// A let statement's value is evaluated before its name is bound, so in
+// `let x = x + 1` the x on the right refers to an earlier binding, and the
+// new binding hides (shadows) that earlier one from then on.
+package scope
+
+import "monkey/ast"
+
+// A name bound by a let statement, together with the identifiers that refer
+// to it
+type Binding struct {
+	Name       *ast.Identifier   // the identifier of the let statement
+	Type       *ast.TypeName     // the type annotation, nil when absent
+	Value      ast.Expression    // the value bound to the name, may be nil
+	References []*ast.Identifier // the identifiers that refer to the binding
+
+	// The binding with the same name that this one hides, if any
+	Shadows *Binding
+}
+
+// The result of resolving the identifiers of a program
+type Info struct {
+	Bindings  []*Binding        // in the order in which they are declared
+	Undefined []*ast.Identifier // identifiers that refer to no binding
+
+	// Maps both the declaring identifier and every reference to their binding
+	Identifiers map[*ast.Identifier]*Binding
+}
+
+// Resolves every identifier of the program
+func Resolve(program *ast.Program) *Info {
+	info := &Info{
+		Identifiers: map[*ast.Identifier]*Binding{},
+	}
+	visible := map[string]*Binding{}
+
+	reference := func(node ast.Node) bool {
+		identifier, ok := node.(*ast.Identifier)
+		if !ok {
+			return true
+		}
+
+		if b, ok := visible[identifier.Identifier]; ok {
+			b.References = append(b.References, identifier)
+			info.Identifiers[identifier] = b
+		} else {
+			info.Undefined = append(info.Undefined, identifier)
+		}
+
+		return true
+	}
+
+	for _, statement := range program.Statements {
+		let, ok := statement.(*ast.LetStatement)
+		if !ok {
+			ast.Inspect(statement, reference)
+			continue
+		}
+
+		if let.Name == nil {
+			continue
+		}
+
+		// only the value, the name itself is not a reference
+		if let.Value != nil {
+			ast.Inspect(let.Value, reference)
+		}
+
+		b := &Binding{
+			Name:    let.Name,
+			Type:    let.Type,
+			Value:   let.Value,
+			Shadows: visible[let.Name.Identifier],
+		}
+		visible[let.Name.Identifier] = b
+		info.Bindings = append(info.Bindings, b)
+		info.Identifiers[let.Name] = b
+	}
+
+	return info
+}
+
+// Returns the binding of the identifier that contains the byte offset, or
+// nil when there is no such identifier or it is undefined
+func (info *Info) BindingAt(offset int) (*Binding, *ast.Identifier) {
+	for identifier, b := range info.Identifiers {
+		start := identifier.Pos().Offset
+		if offset >= start && offset <= start+len(identifier.Identifier) {
+			return b, identifier
+		}
+	}
+
+	return nil, nil
+}
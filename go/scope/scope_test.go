@@ -0,0 +1,60 @@
+package scope
+
+import (
+	"monkey/lexer"
+	"monkey/parser"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	input := `let x = 1;
+let y = x + z;
+let x = x + y;
+x;`
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	info := Resolve(program)
+
+	if len(info.Bindings) != 3 {
+		t.Fatalf("expected 3 bindings, got=%d", len(info.Bindings))
+	}
+
+	first, y, second := info.Bindings[0], info.Bindings[1], info.Bindings[2]
+
+	// the first x is referenced by y and by the value of the second x
+	if len(first.References) != 2 {
+		t.Errorf("expected the first x to have 2 references, got=%d", len(first.References))
+	}
+
+	if len(y.References) != 1 {
+		t.Errorf("expected y to have 1 reference, got=%d", len(y.References))
+	}
+
+	if len(second.References) != 1 || second.References[0].Pos().Line != 4 {
+		t.Errorf("expected the second x to be referenced on line 4, got=%v", second.References)
+	}
+
+	if second.Shadows != first {
+		t.Errorf("expected the second x to shadow the first")
+	}
+
+	if len(info.Undefined) != 1 || info.Undefined[0].Identifier != "z" {
+		t.Errorf("expected z to be undefined, got=%v", info.Undefined)
+	}
+
+	// the x on line 3, column 9 refers to the first binding
+	offset := len("let x = 1;\nlet y = x + z;\nlet x = ")
+	b, identifier := info.BindingAt(offset)
+	if b != first || identifier.Pos().Line != 3 {
+		t.Errorf("expected BindingAt(%d) to find the first x", offset)
+	}
+
+	if b, _ := info.BindingAt(len("let")); b != nil {
+		t.Errorf("expected no binding at a keyword")
+	}
+}
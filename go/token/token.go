@@ -9,18 +9,37 @@
 // as parenthesis, or identifiers for variables or functions.
 package token
 
+import "fmt"
+
 // Represents the type of the token, e.g. an INT or an IDENTIFIER
 type TokenType string
 
 type Token struct {
-	Type    TokenType
-	Literal string // The literal value of the token
+	Type    TokenType `json:"type"`
+	Literal string    `json:"literal"` // The literal value of the token
+	Pos     Position  `json:"pos"`     // Where the token starts in the source code
+}
+
+// The location of a token in the source code
+// Lines and columns start at 1, the offset is the byte index into the source
+type Position struct {
+	Offset int `json:"offset"`
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Formats the position as `line:column`
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
 }
 
 const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
 
+	// Line comments, e.g. `// a comment`
+	COMMENT = "COMMENT"
+
 	// Identifiers + literals
 	IDENT = "IDENT" // add, foobar, x, y, ...
 	INT   = "INT"   // 1343456
@@ -32,10 +51,15 @@ const (
 	SLASH    = "/"
 	ASTERISK = "*"
 	BANG     = "!"
+	PIPE     = "|>"
+	POWER    = "**"
+	QUESTION = "?"
 
 	// Delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
+	COLON     = ":"
+	DOT       = "."
 
 	LPAREN = "("
 	RPAREN = ")"
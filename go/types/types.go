@@ -0,0 +1,239 @@
+// Package types implements a static type checker for the monkey programming
+// language.
+//
+// Monkey is dynamically typed, annotations are optional:
+// `let x: int = 5;`
+//
+// The checker infers the type of every expression bottom-up from its
+// literals and operators, and checks expressions against the type that
+// their context expects: the annotation of a let statement, or the operand
+// types of an operator. Whatever cannot be inferred, e.g. an undefined
+// identifier, has the Unknown type, which is compatible with every other
+// type. Unannotated, well-behaved programs therefore never produce errors.
+package types
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/scope"
+	"monkey/token"
+)
+
+type Type int
+
+const (
+	Unknown Type = iota // could not be inferred, compatible with any type
+	Int
+	Bool
+)
+
+func (t Type) String() string {
+	switch t {
+	case Int:
+		return "int"
+	case Bool:
+		return "bool"
+	}
+
+	return "unknown"
+}
+
+// The types that can be named in annotations
+var named = map[string]Type{
+	"int":  Int,
+	"bool": Bool,
+}
+
+// A type error, at the position of the offending expression
+type Error struct {
+	Pos     token.Position
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Message)
+}
+
+// The result of checking a program
+type Info struct {
+	Types        map[ast.Expression]Type  // the inferred type of every expression
+	Declarations map[*ast.Identifier]Type // the declared or inferred type of every let bound name
+	Errors       []*Error
+}
+
+// Returns the type of the expression, Unknown if it was not checked
+func (info *Info) TypeOf(expression ast.Expression) Type {
+	return info.Types[expression]
+}
+
+type checker struct {
+	scope *scope.Info
+	info  *Info
+}
+
+// Infers the types of the program and reports type errors
+func Check(program *ast.Program) *Info {
+	c := &checker{
+		scope: scope.Resolve(program),
+		info: &Info{
+			Types:        map[ast.Expression]Type{},
+			Declarations: map[*ast.Identifier]Type{},
+		},
+	}
+
+	for _, statement := range program.Statements {
+		switch statement := statement.(type) {
+		case *ast.LetStatement:
+			c.let(statement)
+		case *ast.ReturnStatement:
+			c.infer(statement.Expression)
+		case *ast.ExpressionStatement:
+			c.infer(statement.Expression)
+		}
+	}
+
+	return c.info
+}
+
+func (c *checker) errorf(pos token.Position, format string, args ...any) {
+	c.info.Errors = append(c.info.Errors, &Error{
+		Pos:     pos,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (c *checker) let(statement *ast.LetStatement) {
+	if statement.Name == nil {
+		return
+	}
+
+	if statement.Type == nil {
+		c.info.Declarations[statement.Name] = c.infer(statement.Value)
+		return
+	}
+
+	declared, ok := named[statement.Type.Name]
+	if !ok {
+		c.errorf(statement.Type.Pos(), "unknown type %s", statement.Type.Name)
+		c.infer(statement.Value)
+		return
+	}
+
+	// the annotation wins, such that later uses are checked against it even
+	// when the value does not match
+	c.info.Declarations[statement.Name] = declared
+	c.check(statement.Value, declared)
+}
+
+// Checks that the expression has the expected type
+func (c *checker) check(expression ast.Expression, expected Type) {
+	actual := c.infer(expression)
+
+	if actual != Unknown && expected != Unknown && actual != expected {
+		c.errorf(expression.Pos(), "cannot use %s (%s) as %s", expression, actual, expected)
+	}
+}
+
+// Infers and records the type of the expression
+func (c *checker) infer(expression ast.Expression) Type {
+	if expression == nil {
+		return Unknown
+	}
+
+	t := c.inferType(expression)
+	c.info.Types[expression] = t
+
+	return t
+}
+
+func (c *checker) inferType(expression ast.Expression) Type {
+	switch expression := expression.(type) {
+	case *ast.IntegerLiteral:
+		return Int
+	case *ast.Boolean:
+		return Bool
+	case *ast.Identifier:
+		if binding, ok := c.scope.Identifiers[expression]; ok {
+			return c.info.Declarations[binding.Name]
+		}
+		return Unknown
+	case *ast.PrefixExpression:
+		return c.prefix(expression)
+	case *ast.InfixExpression:
+		return c.infix(expression)
+	case *ast.CallExpression:
+		c.infer(expression.Function)
+		c.inferAll(expression.Arguments)
+	case *ast.MethodCallExpression:
+		c.infer(expression.Receiver)
+		c.inferAll(expression.Arguments)
+	case *ast.PipeExpression:
+		c.infer(expression.Left)
+		c.infer(expression.Right)
+	case *ast.ConditionalExpression:
+		return c.conditional(expression)
+	}
+
+	// functions are not typed, so neither are their results
+	return Unknown
+}
+
+func (c *checker) inferAll(expressions []ast.Expression) {
+	for _, expression := range expressions {
+		c.infer(expression)
+	}
+}
+
+func (c *checker) prefix(expression *ast.PrefixExpression) Type {
+	switch expression.Operator {
+	case "!":
+		// any value can be negated, only false and null are falsy
+		c.infer(expression.Value)
+		return Bool
+	case "-":
+		c.check(expression.Value, Int)
+		return Int
+	}
+
+	c.infer(expression.Value)
+	return Unknown
+}
+
+func (c *checker) infix(expression *ast.InfixExpression) Type {
+	left := c.infer(expression.Left)
+	right := c.infer(expression.Right)
+
+	switch expression.Operator {
+	case "==", "!=":
+		// values of different types are never equal, but can be compared
+		return Bool
+	case "+", "-", "*", "/", "**", "<", ">":
+		if left != Unknown && right != Unknown && left != right {
+			c.errorf(expression.Pos(), "mismatched types %s and %s in %s", left, right, expression)
+		} else if left == Bool || right == Bool {
+			c.errorf(expression.Pos(), "operator %s not defined on bool in %s", expression.Operator, expression)
+		}
+
+		if expression.Operator == "<" || expression.Operator == ">" {
+			return Bool
+		}
+		return Int
+	}
+
+	return Unknown
+}
+
+func (c *checker) conditional(expression *ast.ConditionalExpression) Type {
+	// any value can be a condition, like for `!`
+	c.infer(expression.Condition)
+
+	consequence := c.infer(expression.Consequence)
+	alternative := c.infer(expression.Alternative)
+
+	// the type is only known when both values have it
+	if consequence == alternative {
+		return consequence
+	}
+
+	return Unknown
+}
@@ -0,0 +1,107 @@
+package types
+
+import (
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"reflect"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("input %q: parser errors: %v", input, p.Errors())
+	}
+
+	return program
+}
+
+func TestCheck(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected []string
+	}{
+		// unannotated code keeps working
+		{"let x = 5; let y = x * 2 > 3; y == true;", nil},
+		{"let x = y + 1;", nil},
+		{"1 == true;", nil},
+		{"!5;", nil},
+		{"let x: int = 5; let y: bool = x > 1;", nil},
+		{
+			"1 + true;",
+			[]string{"1:1: mismatched types int and bool in (1 + true)"},
+		},
+		{
+			"true < false;",
+			[]string{"1:1: operator < not defined on bool in (true < false)"},
+		},
+		{
+			"-true;",
+			[]string{"1:2: cannot use true (bool) as int"},
+		},
+		{
+			"let x: bool = 1 + 2;",
+			[]string{"1:15: cannot use (1 + 2) (int) as bool"},
+		},
+		{
+			// the annotation is trusted for later uses of x
+			"let x: bool = 1;\nx + 1;",
+			[]string{
+				"1:15: cannot use 1 (int) as bool",
+				"2:1: mismatched types bool and int in (x + 1)",
+			},
+		},
+		{
+			"let x = 1 > 2;\nlet y = x * 2;",
+			[]string{"2:9: mismatched types bool and int in (x * 2)"},
+		},
+		{
+			"let x: float = 1;",
+			[]string{"1:8: unknown type float"},
+		},
+		{"let x: int = 2 ** 3; let y: int = true ? 1 : 2;", nil},
+		{
+			"let x: int = a ? 1 : false;\nlet y: bool = 1 > 0 ? true : false;",
+			nil,
+		},
+		{
+			"let x: bool = a ? 1 : 2;",
+			[]string{"1:15: cannot use (a ? 1 : 2) (int) as bool"},
+		},
+		{
+			"true ** 2;",
+			[]string{"1:1: mismatched types bool and int in (true ** 2)"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		info := Check(parse(t, testCase.input))
+
+		var actual []string
+		for _, err := range info.Errors {
+			actual = append(actual, err.Error())
+		}
+
+		if !reflect.DeepEqual(actual, testCase.expected) {
+			t.Errorf("input %q:\nexpected=%q\ngot=%q", testCase.input, testCase.expected, actual)
+		}
+	}
+}
+
+func TestInferredTypes(t *testing.T) {
+	program := parse(t, "let a = 1 + 2; let b = !a; let c = a; let d = e;")
+	info := Check(program)
+
+	expected := []Type{Int, Bool, Int, Unknown}
+	for i, statement := range program.Statements {
+		let := statement.(*ast.LetStatement)
+
+		if actual := info.TypeOf(let.Value); actual != expected[i] {
+			t.Errorf("%s: expected=%s, got=%s", let.Name, expected[i], actual)
+		}
+	}
+}